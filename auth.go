@@ -200,11 +200,15 @@ func Auth(opt AuthOption) HandlerFunc {
 		iss, ok := mpClaim["iss"].(string)
 		if !ok {
 			c.Logger().Error("Invalid issuer")
+			c.SetHeader("WWW-Authenticate", "Bearer error=\"invalid_token\"")
 			c.AbortWithStatus(http.StatusUnauthorized)
+			return
 		}
 		if !strings.Contains(iss, opt.Issuer) {
 			c.Logger().Error(fmt.Sprintf("Invalid issuer: %s", opt.Issuer))
+			c.SetHeader("WWW-Authenticate", "Bearer error=\"invalid_token\"")
 			c.AbortWithStatus(http.StatusUnauthorized)
+			return
 		}
 		if len(opt.Audiences) > 0 {
 			noAudience := true
@@ -218,14 +222,12 @@ func Auth(opt AuthOption) HandlerFunc {
 				}
 			}
 			if noAudience {
+				c.SetHeader("WWW-Authenticate", "Bearer error=\"invalid_token\"")
 				c.AbortWithStatus(http.StatusUnauthorized)
+				return
 			}
 		}
-		if !c.IsSkip() {
-			c.Set(AuthKey, token)
-			c.Next()
-		} else {
-			c.SetHeader("WWW-Authenticate", "Bearer error=\"invalid_token\"")
-		}
+		c.Set(AuthKey, token)
+		c.Next()
 	}
 }
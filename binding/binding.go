@@ -1,6 +1,13 @@
 package binding
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
 
 type Binding interface {
 	Name() string
@@ -16,3 +23,73 @@ type StructValidator interface {
 	ValidateStruct(interface{}) error
 	Engine() interface{}
 }
+
+var (
+	JSON     BindingBody = jsonBiding{}
+	XML      BindingBody = xmlBinding{}
+	YAML     BindingBody = yamlBinding{}
+	MsgPack  BindingBody = msgpackBinding{}
+	ProtoBuf BindingBody = protobufBinding{}
+)
+
+var (
+	mu       sync.RWMutex
+	bindings = map[string]Binding{}
+)
+
+func init() {
+	Register(constant.JSON.String(), JSON)
+	Register(constant.XML.String(), XML)
+	Register(constant.XML2.String(), XML)
+	Register(constant.YAML.String(), YAML)
+	Register(constant.YAML2.String(), YAML)
+	Register(constant.MSGPACK.String(), MsgPack)
+	Register(constant.MSGPACK2.String(), MsgPack)
+	Register(constant.ProtocolBuffer.String(), ProtoBuf)
+	Register(constant.POSTForm.String(), Form)
+	Register(constant.MultipartPOSTForm.String(), Form)
+}
+
+// Register is content type に対応する Binding を登録する
+// 	パラメータ(charset など)は無視される
+func Register(contentType string, b Binding) {
+	mu.Lock()
+	defer mu.Unlock()
+	bindings[mediaType(contentType)] = b
+}
+
+// UnsupportedMediaTypeError is Binding が登録されていない content type
+// 	Status() で 415 を返すので fwncs.ToHTTPError は 415 Unsupported Media Type にする
+type UnsupportedMediaTypeError struct {
+	ContentType string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("binding: unsupported media type %q", e.ContentType)
+}
+
+func (e *UnsupportedMediaTypeError) Status() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// Default is content type から Binding を返す
+// 	content type が空の場合は JSON を返し、登録されていない場合は *UnsupportedMediaTypeError を返す
+func Default(contentType string) (Binding, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	name := mediaType(contentType)
+	if name == "" {
+		return JSON, nil
+	}
+	if b, ok := bindings[name]; ok {
+		return b, nil
+	}
+	return nil, &UnsupportedMediaTypeError{ContentType: name}
+}
+
+func mediaType(contentType string) string {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
@@ -0,0 +1,179 @@
+package binding_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/n-creativesystem/go-fwncs/binding"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type bindingUser struct {
+	Name string `json:"name" xml:"name" yaml:"name" msgpack:"name" binding:"required"`
+	Age  int    `json:"age" xml:"age" yaml:"age" msgpack:"age" binding:"gte=0"`
+}
+
+func mustMarshal(buf []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func TestBindingBody(t *testing.T) {
+	newRequest := func(contentType string, body []byte) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(constant.HeaderContentType, contentType)
+		return req
+	}
+	// bindBoth is Bind と BindBody の結果が同じであることを確認して Bind の結果を返す
+	bindBoth := func(t *testing.T, b binding.BindingBody, contentType string, body []byte, newObj func() interface{}) (interface{}, error) {
+		obj := newObj()
+		err := b.Bind(newRequest(contentType, body), obj)
+		fromBody := newObj()
+		bodyErr := b.BindBody(body, fromBody)
+		assert.Equal(t, err == nil, bodyErr == nil)
+		if err == nil {
+			assert.Equal(t, obj, fromBody)
+		}
+		return obj, err
+	}
+	newUser := func() interface{} { return &bindingUser{} }
+	cases := []struct {
+		name        string
+		binding     binding.BindingBody
+		contentType constant.ContentType
+		valid       []byte
+		missing     []byte
+		invalid     []byte
+	}{
+		{
+			name:        "json",
+			binding:     binding.JSON,
+			contentType: constant.JSON,
+			valid:       []byte(`{"name":"taro","age":20}`),
+			missing:     []byte(`{"age":20}`),
+			invalid:     []byte(`{"name":`),
+		},
+		{
+			name:        "xml",
+			binding:     binding.XML,
+			contentType: constant.XML,
+			valid:       []byte(`<bindingUser><name>taro</name><age>20</age></bindingUser>`),
+			missing:     []byte(`<bindingUser><age>20</age></bindingUser>`),
+			invalid:     []byte(`<bindingUser><name>taro</name><age>x</age></bindingUser>`),
+		},
+		{
+			name:        "yaml",
+			binding:     binding.YAML,
+			contentType: constant.YAML,
+			valid:       []byte("name: taro\nage: 20\n"),
+			missing:     []byte("age: 20\n"),
+			invalid:     []byte("name: [taro\n"),
+		},
+		{
+			name:        "msgpack",
+			binding:     binding.MsgPack,
+			contentType: constant.MSGPACK,
+			valid:       mustMarshal(msgpack.Marshal(map[string]interface{}{"name": "taro", "age": 20})),
+			missing:     mustMarshal(msgpack.Marshal(map[string]interface{}{"age": 20})),
+			invalid:     []byte{0xc1},
+		},
+	}
+	tt := tests.TestFrames{}
+	for _, tc := range cases {
+		tc := tc
+		tt = append(tt, tests.TestFrame{Name: tc.name, Fn: func(t *testing.T) {
+			b, err := binding.Default(tc.contentType.String())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.binding, b)
+			assert.Equal(t, tc.name, b.Name())
+
+			obj, err := bindBoth(t, tc.binding, tc.contentType.String(), tc.valid, newUser)
+			assert.NoError(t, err)
+			assert.Equal(t, &bindingUser{Name: "taro", Age: 20}, obj)
+
+			_, err = bindBoth(t, tc.binding, tc.contentType.String(), tc.missing, newUser)
+			_, ok := binding.TranslateError(err)
+			assert.True(t, ok, "validation error: %v", err)
+
+			_, err = bindBoth(t, tc.binding, tc.contentType.String(), tc.invalid, newUser)
+			assert.Error(t, err)
+			_, ok = binding.TranslateError(err)
+			assert.False(t, ok, "decode error: %v", err)
+
+			assert.Error(t, tc.binding.Bind(&http.Request{}, &bindingUser{}))
+		}})
+	}
+	tt = append(tt, tests.TestFrame{Name: "protobuf", Fn: func(t *testing.T) {
+		b, err := binding.Default(constant.ProtocolBuffer.String())
+		assert.NoError(t, err)
+		assert.Equal(t, binding.ProtoBuf, b)
+
+		body := mustMarshal(proto.Marshal(wrapperspb.String("taro")))
+		obj, err := bindBoth(t, binding.ProtoBuf, constant.ProtocolBuffer.String(), body, func() interface{} { return &wrapperspb.StringValue{} })
+		assert.NoError(t, err)
+		assert.Equal(t, "taro", obj.(*wrapperspb.StringValue).GetValue())
+
+		_, err = bindBoth(t, binding.ProtoBuf, constant.ProtocolBuffer.String(), []byte{0xff}, func() interface{} { return &wrapperspb.StringValue{} })
+		assert.Error(t, err)
+		assert.Error(t, binding.ProtoBuf.BindBody(body, &bindingUser{}))
+	}})
+	tt.Run(t)
+}
+
+func TestBindingRegister(t *testing.T) {
+	tt := tests.TestFrames{
+		{Name: "media type parameters", Fn: func(t *testing.T) {
+			for _, contentType := range []string{"application/json; charset=utf-8", "Application/JSON", " application/json "} {
+				b, err := binding.Default(contentType)
+				assert.NoError(t, err, contentType)
+				assert.Equal(t, binding.JSON, b, contentType)
+			}
+			b, err := binding.Default(constant.XML2.String())
+			assert.NoError(t, err)
+			assert.Equal(t, binding.XML, b)
+			// RFC 9512
+			b, err = binding.Default(constant.YAML2.String() + "; charset=utf-8")
+			assert.NoError(t, err)
+			assert.Equal(t, binding.YAML, b)
+			b, err = binding.Default(constant.MultipartPOSTForm.String() + "; boundary=xxx")
+			assert.NoError(t, err)
+			assert.Equal(t, binding.Form, b)
+		}},
+		{Name: "empty content type", Fn: func(t *testing.T) {
+			b, err := binding.Default("")
+			assert.NoError(t, err)
+			assert.Equal(t, binding.JSON, b)
+		}},
+		{Name: "unsupported media type", Fn: func(t *testing.T) {
+			b, err := binding.Default("text/csv; charset=utf-8")
+			assert.Nil(t, b)
+			var unsupported *binding.UnsupportedMediaTypeError
+			if assert.True(t, errors.As(err, &unsupported)) {
+				assert.Equal(t, "text/csv", unsupported.ContentType)
+				assert.Equal(t, http.StatusUnsupportedMediaType, unsupported.Status())
+			}
+		}},
+		{Name: "register", Fn: func(t *testing.T) {
+			binding.Register("application/vnd.fwncs.user+json; version=1", binding.JSON)
+			b, err := binding.Default("application/vnd.fwncs.user+json")
+			assert.NoError(t, err)
+			assert.Equal(t, binding.JSON, b)
+
+			binding.Register("application/vnd.fwncs.user+json", binding.YAML)
+			b, err = binding.Default("application/vnd.fwncs.user+json; version=2")
+			assert.NoError(t, err)
+			assert.Equal(t, binding.YAML, b)
+		}},
+	}
+	tt.Run(t)
+}
@@ -0,0 +1,340 @@
+package binding
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMemory is multipart をまだ読み込んでいない場合にメモリに保持する上限
+// 	Context.Bind, Context.BindWith は Router.MaxMultipartMemory で先に読み込む
+const defaultMemory = 32 << 20
+
+var (
+	Form   Binding    = formBinding{}
+	Query  Binding    = queryBinding{}
+	Header Binding    = headerBinding{}
+	Uri    BindingUri = uriBinding{}
+)
+
+// BindingUri is URL パラメータ (:id など) から読み込む
+type BindingUri interface {
+	Name() string
+	BindUri(params map[string][]string, obj interface{}) error
+}
+
+// Validate is Validator で obj を検証する
+func Validate(obj interface{}) error {
+	return validate(obj)
+}
+
+// MapValues is タグ (query:"page" など) が付いたフィールドに values を設定する (検証はしない)
+// 	タグには default=値 を指定でき、値が無い場合に使われる
+// 	time.Time は time_format タグのレイアウト (デフォルトは time.RFC3339) で変換する
+func MapValues(obj interface{}, values map[string][]string, tag string) error {
+	return mapValues(obj, values, tag, mapOptions{})
+}
+
+// OverwriteValues is MapValues と同じだが、values に無く default も無いフィールドはゼロ値にする
+// 	body を読み込んだ後に path, query, header の値で設定し直し、body から変更できないようにするために使う
+func OverwriteValues(obj interface{}, values map[string][]string, tag string) error {
+	return mapValues(obj, values, tag, mapOptions{overwrite: true})
+}
+
+type mapOptions struct {
+	// useFieldName is タグが無いフィールドはフィールド名で探す
+	useFieldName bool
+	// overwrite is 値が無いフィールドをゼロ値にする
+	overwrite bool
+}
+
+type formBinding struct{}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+func (formBinding) Bind(r *http.Request, obj interface{}) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if err := r.ParseMultipartForm(defaultMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	if err := mapValues(obj, r.Form, "form", mapOptions{useFieldName: true}); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type queryBinding struct{}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+func (queryBinding) Bind(r *http.Request, obj interface{}) error {
+	if err := MapValues(obj, r.URL.Query(), "query"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type headerBinding struct{}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+func (headerBinding) Bind(r *http.Request, obj interface{}) error {
+	if err := MapValues(obj, r.Header, "header"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type uriBinding struct{}
+
+func (uriBinding) Name() string {
+	return "uri"
+}
+
+func (uriBinding) BindUri(params map[string][]string, obj interface{}) error {
+	if err := MapValues(obj, params, "path"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// FieldBindError is 値をフィールドの型に変換できなかった
+type FieldBindError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldBindError) Error() string {
+	return fmt.Sprintf("binding: %s: invalid value %q: %v", e.Field, e.Value, e.Err)
+}
+
+func (e *FieldBindError) Unwrap() error {
+	return e.Err
+}
+
+func mapValues(obj interface{}, values map[string][]string, tag string, options mapOptions) error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("binding: obj must be a non-nil pointer")
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return errors.New("binding: obj must be a pointer to a struct")
+	}
+	lookup := func(key string) ([]string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+	if tag == "header" {
+		lookup = func(key string) ([]string, bool) {
+			v, ok := values[textproto.CanonicalMIMEHeaderKey(key)]
+			return v, ok
+		}
+	}
+	return mapStruct(value, lookup, tag, options)
+}
+
+func mapStruct(value reflect.Value, lookup func(key string) ([]string, bool), tag string, options mapOptions) error {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fieldValue := value.Field(i)
+		name, opts, tagged := parseTag(field, tag, options.useFieldName)
+		if name == "-" {
+			continue
+		}
+		if !tagged {
+			// タグが無い構造体 (埋め込みを含む) は中のフィールドを探す
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !isScalarStruct(ft) {
+				if fieldValue.Kind() == reflect.Ptr {
+					if fieldValue.IsNil() {
+						fieldValue.Set(reflect.New(ft))
+					}
+					fieldValue = fieldValue.Elem()
+				}
+				if err := mapStruct(fieldValue, lookup, tag, options); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		vs, ok := lookup(name)
+		if !ok || len(vs) == 0 {
+			if def, ok := opts["default"]; ok {
+				vs = []string{def}
+			} else {
+				if options.overwrite {
+					fieldValue.Set(reflect.Zero(fieldValue.Type()))
+				}
+				continue
+			}
+		}
+		if err := setField(fieldValue, field, vs); err != nil {
+			return &FieldBindError{Field: name, Value: strings.Join(vs, ","), Err: err}
+		}
+	}
+	return nil
+}
+
+// parseTag is `query:"name,default=1"` を name と options に分割する
+func parseTag(field reflect.StructField, tag string, useFieldName bool) (string, map[string]string, bool) {
+	value, ok := field.Tag.Lookup(tag)
+	if !ok {
+		if !useFieldName || field.Anonymous {
+			return "", nil, false
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !isScalarStruct(ft) {
+			return "", nil, false
+		}
+		return field.Name, nil, true
+	}
+	parts := strings.Split(value, ",")
+	opts := map[string]string{}
+	for _, opt := range parts[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) == 2 {
+			opts[strings.TrimSpace(kv[0])] = kv[1]
+		}
+	}
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		name = field.Name
+	}
+	return name, opts, true
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isScalarStruct is 1 つの値として扱う構造体 (time.Time など) かを返す
+func isScalarStruct(typ reflect.Type) bool {
+	return typ == timeType || reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+func setField(value reflect.Value, field reflect.StructField, vs []string) error {
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(value.Type(), len(vs), len(vs))
+			for i, v := range vs {
+				if err := setValue(slice.Index(i), field, v); err != nil {
+					return err
+				}
+			}
+			value.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if len(vs) != value.Len() {
+			return fmt.Errorf("%d values are required", value.Len())
+		}
+		for i, v := range vs {
+			if err := setValue(value.Index(i), field, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setValue(value, field, vs[0])
+}
+
+func setValue(value reflect.Value, field reflect.StructField, v string) error {
+	if value.Kind() == reflect.Ptr {
+		ptr := reflect.New(value.Type().Elem())
+		if err := setValue(ptr.Elem(), field, v); err != nil {
+			return err
+		}
+		value.Set(ptr)
+		return nil
+	}
+	if value.CanAddr() {
+		if u, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok && value.Type() != timeType {
+			return u.UnmarshalText([]byte(v))
+		}
+	}
+	switch value.Type() {
+	case timeType:
+		layout := field.Tag.Get("time_format")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(v)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(v, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(v, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(v, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(n)
+	case reflect.Slice:
+		// []byte
+		value.SetBytes([]byte(v))
+	case reflect.Interface:
+		value.Set(reflect.ValueOf(v))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackBinding struct{}
+
+var _ BindingBody = msgpackBinding{}
+
+func (msgpackBinding) Name() string {
+	return "msgpack"
+}
+
+func (msgpackBinding) Bind(r *http.Request, obj interface{}) error {
+	if r == nil || r.Body == nil {
+		return errors.New("invalid request")
+	}
+	return decodeMsgPack(r.Body, obj)
+}
+
+func (msgpackBinding) BindBody(buf []byte, obj interface{}) error {
+	return decodeMsgPack(bytes.NewReader(buf), obj)
+}
+
+func decodeMsgPack(r io.Reader, obj interface{}) error {
+	decoder := msgpack.NewDecoder(r)
+	// Go 側の構造体は json タグで定義されていることが多いため、msgpack タグが無い場合は json タグを使う
+	decoder.SetCustomStructTag("json")
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -0,0 +1,39 @@
+package binding
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type protobufBinding struct{}
+
+var _ BindingBody = protobufBinding{}
+
+func (protobufBinding) Name() string {
+	return "protobuf"
+}
+
+func (b protobufBinding) Bind(r *http.Request, obj interface{}) error {
+	if r == nil || r.Body == nil {
+		return errors.New("invalid request")
+	}
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return b.BindBody(buf, obj)
+}
+
+func (protobufBinding) BindBody(buf []byte, obj interface{}) error {
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return errors.New("obj is not proto.Message")
+	}
+	if err := proto.Unmarshal(buf, msg); err != nil {
+		return err
+	}
+	return validate(obj)
+}
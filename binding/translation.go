@@ -0,0 +1,200 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ja"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	ja_translations "github.com/go-playground/validator/v10/translations/ja"
+)
+
+var (
+	ErrUnsupportedValidator = errors.New("binding: validator engine is not *validator.Validate")
+	ErrUnknownLocale        = errors.New("binding: translation for locale is not registered")
+)
+
+// DefaultLocale is 要求された locale の翻訳が無い場合に使う locale
+var DefaultLocale = "en"
+
+// TranslationFunc is validator に翻訳を登録する関数
+// 	validator/v10/translations 配下の RegisterDefaultTranslations と同じシグネチャ
+type TranslationFunc func(v *validator.Validate, trans ut.Translator) error
+
+// FieldError is 1 フィールド分の検証エラー
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is クライアントへ返却する検証エラーの一覧
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+var (
+	uni       = ut.New(en.New())
+	transMu   sync.RWMutex
+	transOnce sync.Once
+	transErr  error
+)
+
+func initTranslations() error {
+	transOnce.Do(func() {
+		if err := registerTranslation(en.New(), en_translations.RegisterDefaultTranslations); err != nil {
+			transErr = err
+			return
+		}
+		if err := registerTranslation(ja.New(), ja_translations.RegisterDefaultTranslations); err != nil {
+			transErr = err
+			return
+		}
+		for locale, texts := range builtinTranslations {
+			for tag, text := range texts {
+				if err := registerTagTranslation(locale, tag, text); err != nil {
+					transErr = err
+					return
+				}
+			}
+		}
+	})
+	return transErr
+}
+
+// RegisterTranslation is locale の翻訳を追加または上書きする
+// 	英語と日本語は初回利用時に自動で登録される
+func RegisterTranslation(locale locales.Translator, fn TranslationFunc) error {
+	if err := initTranslations(); err != nil {
+		return err
+	}
+	return registerTranslation(locale, fn)
+}
+
+func registerTranslation(locale locales.Translator, fn TranslationFunc) error {
+	v, err := engine()
+	if err != nil {
+		return err
+	}
+	transMu.Lock()
+	defer transMu.Unlock()
+	if err := uni.AddTranslator(locale, true); err != nil {
+		return err
+	}
+	trans, _ := uni.GetTranslator(locale.Locale())
+	return fn(v, trans)
+}
+
+// TranslateError is 検証エラーを locale のメッセージを持つ ValidationErrors に変換する
+// 	locale は優先順に指定し、見つからない場合は DefaultLocale になる
+// 	検証エラー以外の場合は ok が false となる
+func TranslateError(err error, locale ...string) (errs ValidationErrors, ok bool) {
+	if err == nil {
+		return nil, false
+	}
+	if e := initTranslations(); e != nil {
+		return nil, false
+	}
+	transMu.RLock()
+	trans, found := uni.FindTranslator(locale...)
+	if !found {
+		trans, _ = uni.GetTranslator(DefaultLocale)
+	}
+	transMu.RUnlock()
+	return translateError(err, trans)
+}
+
+func translateError(err error, trans ut.Translator) (ValidationErrors, bool) {
+	switch e := err.(type) {
+	case ValidationErrors:
+		return e, true
+	case validator.ValidationErrors:
+		errs := make(ValidationErrors, len(e))
+		for i, fe := range e {
+			errs[i] = FieldError{
+				Field:   fe.Field(),
+				Tag:     fe.Tag(),
+				Param:   fe.Param(),
+				Message: fe.Translate(trans),
+			}
+		}
+		return errs, true
+	case sliceValidateError:
+		errs := make(ValidationErrors, 0, len(e))
+		for _, ee := range e {
+			if v, ok := translateError(ee, trans); ok {
+				errs = append(errs, v...)
+			}
+		}
+		return errs, len(errs) > 0
+	}
+	return nil, false
+}
+
+// ParseAcceptLanguage is Accept-Language ヘッダーを q 値の高い順に TranslateError に渡せる locale の一覧に変換する
+// 	ja-JP のような地域付きの指定は ja_JP と ja の両方を返す
+func ParseAcceptLanguage(header string) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+	langs := make([]language, 0)
+	for _, v := range strings.Split(header, ",") {
+		parts := strings.Split(v, ";")
+		tag := strings.TrimSpace(parts[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, language{tag: strings.Replace(tag, "-", "_", -1), q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	tags := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		tags = append(tags, lang.tag)
+		if idx := strings.IndexByte(lang.tag, '_'); idx > 0 {
+			tags = append(tags, lang.tag[:idx])
+		}
+	}
+	return tags
+}
+
+// fieldName is エラーのフィールド名を json, form タグの名前にする
+func fieldName(fld reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(fld.Tag.Get(tag), ",", 2)[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return ""
+}
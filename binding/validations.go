@@ -0,0 +1,138 @@
+package binding
+
+import (
+	"regexp"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// 日本向けの組み込みタグ
+const (
+	TagJPPhone   = "jp_phone"
+	TagZipcodeJP = "zipcode_jp"
+	TagHiragana  = "hiragana"
+	TagKatakana  = "katakana"
+)
+
+var (
+	jpPhoneRegex   = regexp.MustCompile(`^0\d{1,4}-?\d{1,4}-?\d{3,4}$`)
+	zipcodeJPRegex = regexp.MustCompile(`^\d{3}-?\d{4}$`)
+	hiraganaRegex  = regexp.MustCompile(`^[\p{Hiragana}ー\s　]+$`)
+	katakanaRegex  = regexp.MustCompile(`^[\p{Katakana}ー・\s　]+$`)
+)
+
+var builtinValidations = map[string]validator.Func{
+	TagJPPhone:   isJPPhone,
+	TagZipcodeJP: regexValidation(zipcodeJPRegex),
+	TagHiragana:  regexValidation(hiraganaRegex),
+	TagKatakana:  regexValidation(katakanaRegex),
+}
+
+// builtinTranslations is 組み込みタグのメッセージ (locale -> tag -> text)
+var builtinTranslations = map[string]map[string]string{
+	"en": {
+		TagJPPhone:   "{0} must be a valid Japanese phone number",
+		TagZipcodeJP: "{0} must be a valid Japanese postal code",
+		TagHiragana:  "{0} must contain only hiragana",
+		TagKatakana:  "{0} must contain only katakana",
+	},
+	"ja": {
+		TagJPPhone:   "{0}は正しい電話番号でなければなりません",
+		TagZipcodeJP: "{0}は正しい郵便番号でなければなりません",
+		TagHiragana:  "{0}はひらがなで入力してください",
+		TagKatakana:  "{0}はカタカナで入力してください",
+	},
+}
+
+func registerBuiltinValidations(v *validator.Validate) {
+	for tag, fn := range builtinValidations {
+		_ = v.RegisterValidation(tag, fn)
+	}
+}
+
+func isJPPhone(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if !jpPhoneRegex.MatchString(value) {
+		return false
+	}
+	digits := len(strings.Replace(value, "-", "", -1))
+	return digits == 10 || digits == 11
+}
+
+func regexValidation(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return re.MatchString(fl.Field().String())
+	}
+}
+
+func engine() (*validator.Validate, error) {
+	v, ok := Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil, ErrUnsupportedValidator
+	}
+	return v, nil
+}
+
+// RegisterValidation is binding タグで使うカスタムタグを登録する
+// 	検証中に呼び出すと安全ではないため、起動時に登録すること
+func RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error {
+	v, err := engine()
+	if err != nil {
+		return err
+	}
+	return v.RegisterValidation(tag, fn, callValidationEvenIfNull...)
+}
+
+// RegisterStructValidation is types に対する構造体単位(複数フィールドにまたがる)の検証を登録する
+func RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) error {
+	v, err := engine()
+	if err != nil {
+		return err
+	}
+	v.RegisterStructValidation(fn, types...)
+	return nil
+}
+
+// RegisterAlias is 複数のタグをまとめたエイリアスを登録する
+// 	e.g. RegisterAlias("tel", "required,jp_phone")
+func RegisterAlias(alias, tags string) error {
+	v, err := engine()
+	if err != nil {
+		return err
+	}
+	v.RegisterAlias(alias, tags)
+	return nil
+}
+
+// RegisterTagTranslation is tag の locale 向けメッセージを登録する
+// 	text の {0} はフィールド名、{1} はタグのパラメータに置き換えられる
+func RegisterTagTranslation(locale, tag, text string) error {
+	if err := initTranslations(); err != nil {
+		return err
+	}
+	return registerTagTranslation(locale, tag, text)
+}
+
+func registerTagTranslation(locale, tag, text string) error {
+	v, err := engine()
+	if err != nil {
+		return err
+	}
+	transMu.RLock()
+	trans, found := uni.GetTranslator(locale)
+	transMu.RUnlock()
+	if !found {
+		return ErrUnknownLocale
+	}
+	return v.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+		return t.Add(tag, text, true)
+	}, func(t ut.Translator, fe validator.FieldError) string {
+		msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return msg
+	})
+}
@@ -73,6 +73,8 @@ func (v *defaultValidator) lazyinit() {
 	v.once.Do(func() {
 		v.validate = validator.New()
 		v.validate.SetTagName("binding")
+		v.validate.RegisterTagNameFunc(fieldName)
+		registerBuiltinValidations(v.validate)
 	})
 }
 
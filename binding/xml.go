@@ -0,0 +1,36 @@
+package binding
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type xmlBinding struct{}
+
+var _ BindingBody = xmlBinding{}
+
+func (xmlBinding) Name() string {
+	return "xml"
+}
+
+func (xmlBinding) Bind(r *http.Request, obj interface{}) error {
+	if r == nil || r.Body == nil {
+		return errors.New("invalid request")
+	}
+	return decodeXML(r.Body, obj)
+}
+
+func (xmlBinding) BindBody(buf []byte, obj interface{}) error {
+	return decodeXML(bytes.NewReader(buf), obj)
+}
+
+func decodeXML(r io.Reader, obj interface{}) error {
+	decoder := xml.NewDecoder(r)
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -0,0 +1,37 @@
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+type yamlBinding struct{}
+
+var _ BindingBody = yamlBinding{}
+
+func (yamlBinding) Name() string {
+	return "yaml"
+}
+
+func (yamlBinding) Bind(r *http.Request, obj interface{}) error {
+	if r == nil || r.Body == nil {
+		return errors.New("invalid request")
+	}
+	return decodeYAML(r.Body, obj)
+}
+
+func (yamlBinding) BindBody(buf []byte, obj interface{}) error {
+	return decodeYAML(bytes.NewReader(buf), obj)
+}
+
+func decodeYAML(r io.Reader, obj interface{}) error {
+	decoder := yaml.NewDecoder(r)
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
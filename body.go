@@ -0,0 +1,85 @@
+package fwncs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrRequestEntityTooLarge is Router.MaxBodySize を超えた request body を読み込んだ際のエラー
+var ErrRequestEntityTooLarge = NewDefaultResponseBody(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+
+// limitedBody is n バイトを超えて読み込もうとすると ErrRequestEntityTooLarge を返す
+type limitedBody struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+func newLimitedBody(rc io.ReadCloser, n int64) io.ReadCloser {
+	return &limitedBody{rc: rc, n: n}
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrRequestEntityTooLarge
+	}
+	// 上限を超えたかを判定するため 1 バイト多く読み込む
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.rc.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+	n = int(l.n)
+	l.n = -1
+	return n, ErrRequestEntityTooLarge
+}
+
+func (l *limitedBody) Close() error {
+	return l.rc.Close()
+}
+
+// Body is request body を読み込んでキャッシュする
+// 	読み込み後は Request().Body もキャッシュから読み直せるように差し替えるため、何度でも読み込める
+func (c *_context) Body() ([]byte, error) {
+	if c.bodyCached {
+		return c.body, nil
+	}
+	if c.req.Body == nil || c.req.Body == http.NoBody {
+		c.body, c.bodyCached = []byte{}, true
+		return c.body, nil
+	}
+	buf, err := ioutil.ReadAll(c.req.Body)
+	if err != nil {
+		c.abortIfTooLarge(err)
+		return nil, err
+	}
+	c.req.Body.Close()
+	c.body, c.bodyCached = buf, true
+	c.req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	return c.body, nil
+}
+
+func (c *_context) abortIfTooLarge(err error) {
+	if errors.Is(err, ErrRequestEntityTooLarge) {
+		c.AbortWithStatusAndMessage(http.StatusRequestEntityTooLarge, ErrRequestEntityTooLarge)
+	}
+}
+
+// BodyCache is 後続の handler で何度も request body を読み込めるように先にキャッシュする
+func BodyCache() HandlerFunc {
+	return func(c Context) {
+		if _, err := c.Body(); err != nil {
+			c.Error(err)
+			if !c.IsSkip() {
+				c.AbortWithStatusAndErrorMessage(http.StatusBadRequest, err)
+			}
+			return
+		}
+		c.Next()
+	}
+}
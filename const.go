@@ -1,5 +1,12 @@
 package fwncs
 
+import "time"
+
+const (
+	defaultStreamKeepAlive     = 15 * time.Second
+	defaultStreamFlushInterval = time.Second
+)
+
 const (
 	defaultMemory = 32 << 20 // 32 MB
 	Uppercase     = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
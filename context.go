@@ -1,21 +1,33 @@
 package fwncs
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
 	"mime/multipart"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/n-creativesystem/go-fwncs/binding"
 	"github.com/n-creativesystem/go-fwncs/constant"
 	"github.com/n-creativesystem/go-fwncs/render"
 )
 
 type Context interface {
+	// context.Context is リクエストの context に委譲し、Value は見つからない場合に Get のキーを参照する
+	// 	c から派生した context (context.WithTimeout(c, ...) など) を SetContext, SetRequest で設定した場合、
+	// 	Deadline, Done, Err はその context に従い、Value は循環しないように設定前の context から参照する
+	// 	(派生した context で追加した値は GetContext から参照する)
+	// 	handler の終了後に使う場合は Copy した Context を使う
+	context.Context
+
 	/*
 		ResponceWriter
 	*/
@@ -36,7 +48,10 @@ type Context interface {
 	GetContext() context.Context
 	SetContext(ctx context.Context)
 	IsWebSocket() bool
+	// Scheme is 接続元が信頼するプロキシの場合のみ Router.SchemeHeaders を参照する
 	Scheme() string
+	// Host is 接続元が信頼するプロキシの場合のみ Router.HostHeaders を参照する
+	Host() string
 
 	/*
 		Abort or error
@@ -44,6 +59,15 @@ type Context interface {
 	AbortWithStatus(status int)
 	AbortWithStatusAndErrorMessage(status int, err error)
 	AbortWithStatusAndMessage(status int, v interface{})
+	// AbortWithBindError is 検証エラーを Accept-Language の言語に翻訳して 400 で返す
+	// 	415 の binding.UnsupportedMediaTypeError など Status() を持つエラーはそのステータスで返す
+	AbortWithBindError(err error)
+	// AbortWithError is 後続の処理を止めてエラーを記録する
+	// 	何も書き込まれていなければ Router.ErrorHandler がエラーを返却する
+	// 	err が *HTTPError の場合は status は無視される
+	AbortWithError(status int, err error)
+	// AbortWithProblem is 後続の処理を止めて RFC 7807 の application/problem+json を返す
+	AbortWithProblem(p *Problem)
 	Error(err error)
 	GetError() []error
 	// Skip is 後続の処理を止める
@@ -55,16 +79,37 @@ type Context interface {
 	*/
 	Param(name string) string
 	Params() Params
+	// FullPath is マッチしたルートのパス (/users/:id など)
+	FullPath() string
 	QueryParam(name string) string
 	DefaultQuery(name string, defaultValue string) string
+	// 型付きアクセサは値が無い場合や変換に失敗した場合に *ParamError を返す
+	ParamInt(name string) (int, error)
+	ParamInt64(name string) (int64, error)
+	QueryInt(name string) (int, error)
+	QueryInt64(name string) (int64, error)
+	QueryBool(name string) (bool, error)
+	// QueryTime when the layout is empty, the default layout is time.RFC3339
+	QueryTime(name string, layout string) (time.Time, error)
+	QueryArray(name string) []string
+	// QueryMap is filter[name]=x のようなクエリを map[name]x にする
+	QueryMap(name string) map[string]string
 
 	/*
 		Request body
 	*/
 	ReadJsonBody(v interface{}) error
+	// Body is request body をキャッシュして返す (Router.MaxBodySize を超えた場合は 413)
+	Body() ([]byte, error)
+	// Bind is Content-Type に応じた binding で request body を v に読み込む
+	// 	binding が登録されていない Content-Type の場合は 415 になる *binding.UnsupportedMediaTypeError を返す
+	// 	構文エラーなどで読み込めなかった場合は 400 になる *BindError を返す (検証エラーはそのまま返す)
+	Bind(v interface{}) error
+	BindWith(v interface{}, b binding.Binding) error
 	FormValue(name string) string
 	FormFile(name string) (*multipart.FileHeader, error)
 	MultiPartForm() (*multipart.Form, error)
+	PostFormArray(name string) []string
 
 	/*
 		Cookie
@@ -81,10 +126,61 @@ type Context interface {
 	JSON(status int, v interface{})
 	JSONP(status int, v interface{})
 	IndentJSON(status int, v interface{}, indent string)
+	// AsciiJSON is ASCII 以外の文字を \uXXXX にエスケープする
 	AsciiJSON(status int, v interface{})
+	// PureJSON is <, >, & を HTML エスケープしない
+	PureJSON(status int, v interface{})
+	// SecureJSON is トップレベルが配列の場合に Router.SecureJSONPrefix を先頭に付ける
+	SecureJSON(status int, v interface{})
 	YAML(status int, v interface{})
+	XML(status int, v interface{})
+	MsgPack(status int, v interface{})
+	// ProtoBuf is v は proto.Message でなければならない
+	ProtoBuf(status int, v interface{})
+	// HTML is Router.LoadHTMLGlob, Router.LoadHTMLFS で読み込んだテンプレートを返却する
+	// 	テンプレートの実行に失敗した場合は 500 のエラーになる
+	HTML(status int, name string, data interface{})
+	// Template, TemplateText is 呼び出し毎にテンプレートを解析するので、繰り返し使う場合は LoadHTMLGlob と HTML を使う
+	// 	解析や実行に失敗した場合は Error に追加して 500 で中断する
 	Template(status int, v interface{}, filenames ...string)
 	TemplateText(status int, text string, v interface{})
+	// File, FileFromFS, Attachment, DataFromReader は Range, If-Range, ETag, Last-Modified に対応する
+	File(name string)
+	FileFromFS(name string, fs http.FileSystem)
+	// Attachment is filename を RFC 6266 の Content-Disposition でダウンロードさせる
+	Attachment(name, filename string)
+	// CSV is rows を filename (空の場合は Content-Disposition を付けない) でダウンロードさせる
+	// 	rows がスライスの場合は render.CSV、チャネルか render.Iterator の場合は render.CSVStream で書き込む
+	// 	filename の拡張子が .tsv の場合はタブ区切りになり、BOM や Shift_JIS は render.CSV, render.CSVStream を渡して指定する
+	CSV(status int, filename string, rows interface{})
+	// DataFromReader is reader が io.ReadSeeker で status が 200 の場合のみ Range に対応する
+	DataFromReader(status int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string)
+	// Negotiate is Accept ヘッダーから最適なフォーマットを選んで返却する
+	// 	受け入れ可能なフォーマットが無い場合は 406 となる
+	Negotiate(status int, config Negotiation)
+	// NegotiateFormat is offers の中から Accept ヘッダーに最も適したものを返す
+	// 	受け入れ可能なものが無い場合は空文字を返す
+	NegotiateFormat(offers ...string) string
+	// Problem is RFC 7807 の application/problem+json を返す (Status が 0 の場合は 500)
+	Problem(p *Problem)
+
+	/*
+		Streaming
+	*/
+	// Stream is step が false を返すかクライアントが切断するまで step を繰り返し、都度 Flush する
+	// 	レスポンスの Content-Type が text/event-stream の場合は SSE 用のヘッダーを設定し、Router.StreamKeepAlive 毎に keep-alive コメントを送る
+	// 	(Stream の前に設定していない場合は SSEvent などで設定された後の step から keep-alive を送る)
+	// 	クライアントが切断した場合は true を返す
+	Stream(step func(w io.Writer) bool) bool
+	SSEvent(name string, data interface{})
+	SSEventWithID(id, name string, data interface{})
+	// LastEventID is 再接続時にクライアントが送る Last-Event-ID
+	LastEventID() string
+	// NDJSON, JSONArray is items (任意の要素型のチャネルか render.Iterator) の値を順番に JSON で書き込む
+	// 	Router.StreamFlushInterval 毎に Flush し、クライアントが切断した場合は止める
+	// 	書き込む前のエラーは 500 になり、書き込み始めた後のエラーはログに出力する
+	NDJSON(status int, items interface{})
+	JSONArray(status int, items interface{})
 
 	/*
 		Middlewere or handler
@@ -92,11 +188,19 @@ type Context interface {
 	Next()
 	HandlerName() string
 	Logger() ILogger
+	// ClientIP is 接続元が Router.SetTrustedProxies で信頼したプロキシの場合のみ Router.RemoteIPHeaders を参照する
 	ClientIP() string
 	Set(key string, value interface{})
 	Get(key string) interface{}
 	Redirect(status int, url string)
 	GetRequestID() string
+	// Copy is handler の終了後も goroutine で使える読み取り専用のスナップショットを返す
+	// 	context はキャンセルされず、レスポンスへの書き込みは ErrCopiedContext になる
+	// 	(JSON などの Render は書き込まずに警告をログに出力する)
+	Copy() Context
+	// Go is Copy した Context で fn を goroutine で実行する
+	// 	panic は recover してリクエスト ID と共にログに出力する
+	Go(fn func(c Context))
 
 	/*
 		Utils
@@ -110,22 +214,33 @@ type Context interface {
 }
 
 type _context struct {
-	router   *Router
-	w        ResponseWriter
-	req      *http.Request
-	params   *Params
-	logger   ILogger
-	skip     bool
-	handler  HandlerFuncChain
-	index    int
-	mp       map[string]interface{}
-	errs     []error
-	mu       sync.Mutex
-	query    url.Values
-	path     string
-	method   string
-	_Params  Params
-	fullPath string
+	router     *Router
+	w          ResponseWriter
+	req        *http.Request
+	params     *Params
+	logger     ILogger
+	skip       bool
+	handler    HandlerFuncChain
+	index      int
+	mp         map[string]interface{}
+	errs       []error
+	mu         sync.Mutex
+	query      url.Values
+	path       string
+	method     string
+	_Params    Params
+	fullPath   string
+	body       []byte
+	bodyCached bool
+	// ctxMu is c から派生した context のタイマーなどが別の goroutine から Done などを呼ぶため、以下のフィールドを保護する
+	ctxMu sync.RWMutex
+	// parent is Value が委譲する context (c から派生した context は含まない)
+	parent context.Context
+	// deadline, done, errCtx is Deadline, Done, Err が返す値と Err を委譲する context
+	deadline    time.Time
+	hasDeadline bool
+	done        <-chan struct{}
+	errCtx      context.Context
 }
 
 var _ Context = &_context{}
@@ -143,7 +258,14 @@ func (c *_context) reset(w http.ResponseWriter, r *http.Request) {
 	c.mu = sync.Mutex{}
 	c.query = r.URL.Query()
 	c.path = ""
+	c.fullPath = ""
 	c.method = ""
+	c.body = nil
+	c.bodyCached = false
+	c.setParent(r.Context())
+	if c.router.MaxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+		r.Body = newLimitedBody(r.Body, c.router.MaxBodySize)
+	}
 }
 
 func (c *_context) Writer() ResponseWriter {
@@ -175,6 +297,7 @@ func (c *_context) Request() *http.Request {
 }
 
 func (c *_context) SetRequest(r *http.Request) {
+	c.setParent(r.Context())
 	*c.req = *r
 }
 
@@ -187,9 +310,73 @@ func (c *_context) GetContext() context.Context {
 }
 
 func (c *_context) SetContext(ctx context.Context) {
+	c.setParent(ctx)
 	*c.req = *c.req.WithContext(ctx)
 }
 
+// setParent is c の Deadline, Done, Err, Value が委譲する context を設定する
+// 	c から派生した context の Deadline などは c を呼び出すことがあるため、循環しないように設定時に解決しておく
+func (c *_context) setParent(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	done := ctx.Done()
+	derived := ctx.Value(contextSelfKey{}) == c
+	c.ctxMu.Lock()
+	defer c.ctxMu.Unlock()
+	errCtx := ctx
+	if derived {
+		// WithValue などキャンセルを追加しない context の Err は c に戻るので、キャンセルの元の context を使う
+		if done == c.done {
+			errCtx = c.errCtx
+		}
+	} else {
+		c.parent = ctx
+	}
+	c.deadline, c.hasDeadline, c.done, c.errCtx = deadline, ok, done, errCtx
+}
+
+// contextSelfKey is context が c から派生しているかを調べるためのキー
+type contextSelfKey struct{}
+
+func (c *_context) Deadline() (deadline time.Time, ok bool) {
+	c.ctxMu.RLock()
+	defer c.ctxMu.RUnlock()
+	return c.deadline, c.hasDeadline
+}
+
+func (c *_context) Done() <-chan struct{} {
+	c.ctxMu.RLock()
+	defer c.ctxMu.RUnlock()
+	return c.done
+}
+
+func (c *_context) Err() error {
+	c.ctxMu.RLock()
+	errCtx := c.errCtx
+	c.ctxMu.RUnlock()
+	if errCtx == nil {
+		return nil
+	}
+	return errCtx.Err()
+}
+
+func (c *_context) Value(key interface{}) interface{} {
+	if _, ok := key.(contextSelfKey); ok {
+		return c
+	}
+	c.ctxMu.RLock()
+	parent := c.parent
+	c.ctxMu.RUnlock()
+	if parent != nil {
+		if value := parent.Value(key); value != nil {
+			return value
+		}
+	}
+	if k, ok := key.(string); ok {
+		return c.Get(k)
+	}
+	return nil
+}
+
 func (c *_context) IsWebSocket() bool {
 	upgrade := c.Header().Get(constant.HeaderUpgrade)
 	return strings.EqualFold(upgrade, "websocket")
@@ -199,21 +386,39 @@ func (c *_context) Scheme() string {
 	if c.req.TLS != nil {
 		return "https"
 	}
-	if scheme := c.req.Header.Get(constant.HeaderXForwardedProto); scheme != "" {
-		return scheme
-	}
-	if scheme := c.req.Header.Get(constant.HeaderXForwardedProtocol); scheme != "" {
-		return scheme
+	if !c.router.isTrustedProxy(remoteIP(c.req)) {
+		return "http"
 	}
-	if ssl := c.req.Header.Get(constant.HeaderXForwardedSsl); ssl == "on" {
-		return "https"
-	}
-	if scheme := c.req.Header.Get(constant.HeaderXUrlScheme); scheme != "" {
-		return scheme
+	for _, name := range c.router.SchemeHeaders {
+		scheme := c.router.forwardedValue(c.req.Header, name, func(e ForwardedElement) string { return e.Proto })
+		if scheme == "" {
+			continue
+		}
+		if http.CanonicalHeaderKey(name) == constant.HeaderXForwardedSsl {
+			if strings.EqualFold(scheme, "on") {
+				return "https"
+			}
+			continue
+		}
+		// 転送ヘッダーの値はリダイレクト先の URL などに使われるので http, https 以外は無視する
+		if scheme = strings.ToLower(scheme); scheme == "http" || scheme == "https" {
+			return scheme
+		}
 	}
 	return "http"
 }
 
+func (c *_context) Host() string {
+	if c.router.isTrustedProxy(remoteIP(c.req)) {
+		for _, name := range c.router.HostHeaders {
+			if host := c.router.forwardedValue(c.req.Header, name, func(e ForwardedElement) string { return e.Host }); host != "" {
+				return host
+			}
+		}
+	}
+	return c.req.Host
+}
+
 func (c *_context) AbortWithStatusAndMessage(status int, v interface{}) {
 	if !c.IsSkip() {
 		c.Skip()
@@ -224,6 +429,15 @@ func (c *_context) AbortWithStatusAndMessage(status int, v interface{}) {
 func (c *_context) AbortWithStatusAndErrorMessage(status int, err error) {
 	if !c.IsSkip() {
 		c.Skip()
+		if c.router.ProblemDetails {
+			detail := err.Error()
+			var body *DefaultResponseBody
+			if errors.As(err, &body) {
+				detail = body.Message
+			}
+			c.Problem(NewProblem(status, detail))
+			return
+		}
 		type errorBody struct {
 			Status   int    `json:"status"`
 			Message  string `json:"message"`
@@ -238,6 +452,21 @@ func (c *_context) AbortWithStatusAndErrorMessage(status int, err error) {
 	}
 }
 
+func (c *_context) AbortWithBindError(err error) {
+	locales := binding.ParseAcceptLanguage(c.Header().Get(constant.HeaderAcceptLanguage))
+	if errs, ok := binding.TranslateError(err, locales...); ok {
+		c.AbortWithStatusAndMessage(http.StatusBadRequest, errs)
+		return
+	}
+	// 415 の binding.UnsupportedMediaTypeError などはそのステータスにする
+	var withStatus interface{ Status() int }
+	if errors.As(err, &withStatus) {
+		c.AbortWithStatusAndErrorMessage(withStatus.Status(), err)
+		return
+	}
+	c.AbortWithStatusAndErrorMessage(http.StatusBadRequest, err)
+}
+
 func (c *_context) Error(err error) {
 	c.errs = append(c.errs, err)
 }
@@ -262,29 +491,44 @@ func (c *_context) Params() Params {
 	return *c.params
 }
 
+func (c *_context) FullPath() string {
+	return c.fullPath
+}
+
 func (c *_context) Logger() ILogger {
 	return c.logger
 }
 
 func (c *_context) ClientIP() string {
-	clientIP := c.Header().Get(constant.HeaderXForwardedFor)
-	clientIP = strings.TrimSpace(strings.Split(clientIP, ",")[0])
-	if clientIP == "" {
-		clientIP = strings.TrimSpace(c.Header().Get(constant.HeaderXRealIP))
+	ip := remoteIP(c.req)
+	if ip == nil {
+		return ""
 	}
-	if clientIP != "" {
-		return clientIP
+	if !c.router.isTrustedProxy(ip) {
+		return ip.String()
 	}
-
-	if ip, _, err := net.SplitHostPort(strings.TrimSpace(c.req.RemoteAddr)); err == nil {
-		return ip
+	for _, name := range c.router.RemoteIPHeaders {
+		if http.CanonicalHeaderKey(name) == constant.HeaderForwarded {
+			// 難読化されたノードの場合はアドレスが分からないので次のヘッダーを使う
+			if hop, ok := c.router.forwardedHop(c.req.Header); ok && nodeIP(hop.For) != "" {
+				return nodeIP(hop.For)
+			}
+			continue
+		}
+		if clientIP, ok := c.router.forwardedClientIP(headerValues(c.req.Header, name)); ok {
+			return clientIP
+		}
 	}
-	return ""
+	return ip.String()
 }
 
 func (c *_context) AbortWithStatus(status int) {
 	if !c.IsSkip() {
 		c.Skip()
+		if c.router.ProblemDetails && status >= http.StatusBadRequest {
+			c.Problem(NewProblem(status, ""))
+			return
+		}
 		c.w.WriteHeader(status)
 	}
 }
@@ -300,6 +544,7 @@ func (c *_context) Next() {
 			break
 		}
 	}
+	c.handleErrors()
 }
 
 func (c *_context) Set(key string, value interface{}) {
@@ -329,7 +574,34 @@ func (c *_context) GetRequestID() string {
 }
 
 func (c *_context) ReadJsonBody(v interface{}) error {
-	return json.NewDecoder(c.req.Body).Decode(v)
+	err := json.NewDecoder(c.req.Body).Decode(v)
+	c.abortIfTooLarge(err)
+	return bindError(err)
+}
+
+func (c *_context) Bind(v interface{}) error {
+	b, err := binding.Default(c.Header().Get(constant.HeaderContentType))
+	if err != nil {
+		return err
+	}
+	return c.BindWith(v, b)
+}
+
+func (c *_context) BindWith(v interface{}, b binding.Binding) error {
+	if b == binding.Form {
+		// binding.Form が読み込む前に Router.MaxMultipartMemory で multipart を読み込んでおく
+		if _, err := c.MultiPartForm(); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return bindError(err)
+		}
+	}
+	var err error
+	if bb, ok := b.(binding.BindingBody); ok && c.bodyCached {
+		err = bb.BindBody(c.body, v)
+	} else {
+		err = b.Bind(c.req, v)
+	}
+	c.abortIfTooLarge(err)
+	return bindError(err)
 }
 
 func (c *_context) Redirect(status int, url string) {
@@ -380,7 +652,8 @@ func (c *_context) FormFile(name string) (*multipart.FileHeader, error) {
 }
 
 func (c *_context) MultiPartForm() (*multipart.Form, error) {
-	err := c.req.ParseMultipartForm(defaultMemory)
+	err := c.req.ParseMultipartForm(c.router.MaxMultipartMemory)
+	c.abortIfTooLarge(err)
 	return c.req.MultipartForm, err
 }
 
@@ -400,6 +673,11 @@ func (c *_context) Render(status int, r render.Render) {
 		return
 	}
 	if err := r.Render(w); err != nil {
+		// Copy した Context は handler の終了後に使われるので、panic せずにログだけ出力する
+		if errors.Is(err, ErrCopiedContext) {
+			c.Logger().Warning(fmt.Sprintf("%v: request_id=%s, path=%s", err, c.GetRequestID(), c.req.URL.Path))
+			return
+		}
 		panic(err)
 	}
 }
@@ -425,6 +703,14 @@ func (c *_context) AsciiJSON(status int, v interface{}) {
 	c.Render(status, render.AsciiJSON{Data: v})
 }
 
+func (c *_context) PureJSON(status int, v interface{}) {
+	c.Render(status, render.PureJSON{Data: v})
+}
+
+func (c *_context) SecureJSON(status int, v interface{}) {
+	c.Render(status, render.SecureJSON{Prefix: c.router.SecureJSONPrefix, Data: v})
+}
+
 func (c *_context) String(status int, format string, v ...interface{}) {
 	c.Render(status, render.Text{Format: format, Data: v})
 }
@@ -433,18 +719,49 @@ func (c *_context) YAML(status int, v interface{}) {
 	c.Render(status, render.YAML{Data: v})
 }
 
+func (c *_context) XML(status int, v interface{}) {
+	c.Render(status, render.XML{Data: v})
+}
+
+func (c *_context) MsgPack(status int, v interface{}) {
+	c.Render(status, render.MsgPack{Data: v})
+}
+
+func (c *_context) ProtoBuf(status int, v interface{}) {
+	c.Render(status, render.ProtoBuf{Data: v})
+}
+
+func (c *_context) HTML(status int, name string, data interface{}) {
+	buf, err := c.router.html.execute(c, name, data)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.Render(status, render.Data{ContentType: constant.HTML.String(), Data: buf})
+}
+
 func (c *_context) Template(status int, v interface{}, filenames ...string) {
-	c.Render(status, render.TemplateRender{
-		Template: template.Must(template.New("html").ParseFiles(filenames...)),
-		Data:     v,
-	})
+	t, err := template.ParseFiles(filenames...)
+	c.renderTemplate(status, t, err, v)
 }
 
 func (c *_context) TemplateText(status int, text string, v interface{}) {
-	c.Render(status, render.TemplateRender{
-		Template: template.Must(template.New("html").Parse(text)),
-		Data:     v,
-	})
+	t, err := template.New("html").Parse(text)
+	c.renderTemplate(status, t, err, v)
+}
+
+// renderTemplate is HTML と同じく、途中まで書き込まないようにバッファに実行してから返却する
+func (c *_context) renderTemplate(status int, t *template.Template, err error, v interface{}) {
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, v); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.Render(status, render.Data{ContentType: constant.HTML.String(), Data: buf.Bytes()})
 }
 
 func (c *_context) Path() string {
@@ -0,0 +1,812 @@
+package fwncs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/binding"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type bindRequest struct {
+	Name  string `json:"name" xml:"name" yaml:"name" binding:"required"`
+	Email string `json:"email" xml:"email" yaml:"email" binding:"omitempty,email"`
+}
+
+func TestContextBind(t *testing.T) {
+	newRouter := func() *fwncs.Router {
+		router := fwncs.New()
+		router.POST("/", func(c fwncs.Context) {
+			var req bindRequest
+			if err := c.Bind(&req); err != nil {
+				c.AbortWithBindError(err)
+				return
+			}
+			c.String(http.StatusOK, req.Name)
+		})
+		return router
+	}
+	tt := tests.TestFrames{
+		{
+			Name: "content type dispatch",
+			Fn: func(t *testing.T) {
+				router := newRouter()
+				bodies := map[constant.ContentType]string{
+					constant.JSON: `{"name":"json"}`,
+					constant.XML:  `<bindRequest><name>xml</name></bindRequest>`,
+					constant.XML2: `<bindRequest><name>xml2</name></bindRequest>`,
+					constant.YAML: "name: yaml",
+				}
+				expected := map[constant.ContentType]string{
+					constant.JSON: "json",
+					constant.XML:  "xml",
+					constant.XML2: "xml2",
+					constant.YAML: "yaml",
+				}
+				for contentType, body := range bodies {
+					req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+					req.Header.Set(constant.HeaderContentType, contentType.String())
+					rw := httptest.NewRecorder()
+					router.ServeHTTP(rw, req)
+					assert.Equal(t, http.StatusOK, rw.Code, contentType)
+					assert.Equal(t, expected[contentType], rw.Body.String(), contentType)
+				}
+			},
+		},
+		{
+			Name: "unsupported media type",
+			Fn: func(t *testing.T) {
+				router := newRouter()
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("name,email\ncsv,"))
+				req.Header.Set(constant.HeaderContentType, constant.CSV.String())
+				rw := httptest.NewRecorder()
+				router.ServeHTTP(rw, req)
+				assert.Equal(t, http.StatusUnsupportedMediaType, rw.Code)
+			},
+		},
+		{
+			Name: "translated validation error",
+			Fn: func(t *testing.T) {
+				router := newRouter()
+				for lang, message := range map[string]string{
+					"":                  "name is a required field",
+					"ja-JP,ja;q=0.9":    "nameは必須フィールドです",
+					"fr;q=0.5,ja;q=0.8": "nameは必須フィールドです",
+				} {
+					req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"email":"invalid"}`))
+					req.Header.Set(constant.HeaderContentType, constant.JSON.String())
+					req.Header.Set(constant.HeaderAcceptLanguage, lang)
+					rw := httptest.NewRecorder()
+					router.ServeHTTP(rw, req)
+					assert.Equal(t, http.StatusBadRequest, rw.Code)
+					var errs binding.ValidationErrors
+					if assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &errs)) && assert.Len(t, errs, 2) {
+						assert.Equal(t, binding.FieldError{Field: "name", Tag: "required", Message: message}, errs[0])
+						assert.Equal(t, "email", errs[1].Field)
+						assert.Equal(t, "email", errs[1].Tag)
+					}
+				}
+			},
+		},
+	}
+	tt.Run(t)
+}
+
+type customValidationRequest struct {
+	Tel      string `json:"tel" binding:"jp_phone"`
+	Zipcode  string `json:"zipcode" binding:"zipcode_jp"`
+	Kana     string `json:"kana" binding:"omitempty,katakana"`
+	Code     string `json:"code" binding:"omitempty,fwncs_code"`
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"`
+}
+
+func TestCustomValidation(t *testing.T) {
+	assert.NoError(t, binding.RegisterValidation("fwncs_code", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "FW-")
+	}))
+	assert.NoError(t, binding.RegisterAlias("fwncs_alias", "required,fwncs_code"))
+	assert.NoError(t, binding.RegisterTagTranslation("ja", "fwncs_code", "{0}はFW-で始まる必要があります"))
+	assert.NoError(t, binding.RegisterStructValidation(func(sl validator.StructLevel) {
+		req := sl.Current().Interface().(customValidationRequest)
+		if req.Password != req.Confirm {
+			sl.ReportError(req.Confirm, "confirm", "Confirm", "eqfield", "password")
+		}
+	}, customValidationRequest{}))
+
+	valid := customValidationRequest{Tel: "03-1234-5678", Zipcode: "100-0001", Kana: "テスト", Code: "FW-1"}
+	assert.NoError(t, binding.Validator.ValidateStruct(valid))
+	valid.Tel = "09012345678"
+	valid.Zipcode = "1000001"
+	assert.NoError(t, binding.Validator.ValidateStruct(valid))
+
+	invalid := customValidationRequest{Tel: "123", Zipcode: "1000", Kana: "てすと", Code: "X", Password: "a", Confirm: "b"}
+	err := binding.Validator.ValidateStruct(invalid)
+	errs, ok := binding.TranslateError(err, "ja")
+	if assert.True(t, ok) && assert.Len(t, errs, 5) {
+		messages := map[string]string{}
+		for _, e := range errs {
+			messages[e.Field] = e.Message
+		}
+		assert.Equal(t, "telは正しい電話番号でなければなりません", messages["tel"])
+		assert.Equal(t, "zipcodeは正しい郵便番号でなければなりません", messages["zipcode"])
+		assert.Equal(t, "kanaはカタカナで入力してください", messages["kana"])
+		assert.Equal(t, "codeはFW-で始まる必要があります", messages["code"])
+		assert.Contains(t, messages, "confirm")
+	}
+
+	type aliasRequest struct {
+		Code string `json:"code" binding:"fwncs_alias"`
+	}
+	err = binding.Validator.ValidateStruct(aliasRequest{})
+	errs, ok = binding.TranslateError(err)
+	if assert.True(t, ok) && assert.Len(t, errs, 1) {
+		assert.Equal(t, "fwncs_alias", errs[0].Tag)
+	}
+}
+
+type negotiateBody struct {
+	Message string `json:"message" xml:"message" yaml:"message"`
+}
+
+func TestNegotiate(t *testing.T) {
+	router := fwncs.New()
+	router.GET("/", func(c fwncs.Context) {
+		c.Negotiate(http.StatusOK, fwncs.Negotiation{
+			Data: negotiateBody{Message: "hello"},
+		})
+	})
+	router.GET("/format", func(c fwncs.Context) {
+		c.String(http.StatusOK, c.NegotiateFormat("application/json", "text/html"))
+	})
+	router.GET("/offered", func(c fwncs.Context) {
+		c.Negotiate(http.StatusOK, fwncs.Negotiation{
+			Offered: []string{constant.ProtocolBuffer.String(), constant.HTML.String(), constant.JSON.String()},
+			Data:    negotiateBody{Message: "hello"},
+		})
+	})
+	router.GET("/nil", func(c fwncs.Context) {
+		c.Negotiate(http.StatusOK, fwncs.Negotiation{
+			Offered: []string{constant.JSON.String(), constant.XML.String()},
+			XML:     negotiateBody{Message: "hello"},
+		})
+	})
+	router.GET("/map", func(c fwncs.Context) {
+		c.Negotiate(http.StatusOK, fwncs.Negotiation{
+			Data: map[string]interface{}{"message": "hello"},
+		})
+	})
+	tt := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"", http.StatusOK, constant.JSON.String()},
+		{"application/xml", http.StatusOK, constant.XML.String()},
+		{"application/json;q=0.5, application/x-yaml", http.StatusOK, constant.YAML.String()},
+		{"text/*, application/*;q=0.2, application/xml;q=0.9", http.StatusOK, constant.XML.String()},
+		{"*/*", http.StatusOK, constant.JSON.String()},
+		{"application/json;q=0, */*;q=0.1", http.StatusOK, constant.XML.String()},
+		{"image/png", http.StatusNotAcceptable, ""},
+	}
+	for _, tc := range tt {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(constant.HeaderAccept, tc.accept)
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, tc.status, rw.Code, tc.accept)
+		if tc.contentType != "" {
+			assert.Equal(t, tc.contentType, rw.Header().Get(constant.HeaderContentType), tc.accept)
+			assert.Contains(t, rw.Body.String(), "hello", tc.accept)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/format", nil)
+	req.Header.Set(constant.HeaderAccept, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "text/html", rw.Body.String())
+
+	// proto.Message でない Data は Offered に Protocol Buffers があっても返却しない
+	for accept, status := range map[string]int{
+		constant.ProtocolBuffer.String(): http.StatusNotAcceptable,
+		"text/html":                      http.StatusNotAcceptable,
+		constant.ProtocolBuffer.String() + ", application/json;q=0.5": http.StatusOK,
+	} {
+		req = httptest.NewRequest(http.MethodGet, "/offered", nil)
+		req.Header.Set(constant.HeaderAccept, accept)
+		rw = httptest.NewRecorder()
+		assert.NotPanics(t, func() { router.ServeHTTP(rw, req) }, accept)
+		assert.Equal(t, status, rw.Code, accept)
+	}
+
+	// 値が nil のフォーマットは null を返さずに提示しない
+	for accept, contentType := range map[string]string{
+		"application/json":                        "",
+		"application/json, application/xml;q=0.5": constant.XML.String(),
+	} {
+		req = httptest.NewRequest(http.MethodGet, "/nil", nil)
+		req.Header.Set(constant.HeaderAccept, accept)
+		rw = httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		assert.Equal(t, contentType, rw.Header().Get(constant.HeaderContentType), accept)
+		assert.NotContains(t, rw.Body.String(), "null", accept)
+	}
+
+	// map は XML にエンコードできないので提示しない
+	for accept, contentType := range map[string]string{
+		"application/xml":                         "",
+		"application/xml, application/json;q=0.5": constant.JSON.String(),
+		"": constant.JSON.String(),
+	} {
+		req = httptest.NewRequest(http.MethodGet, "/map", nil)
+		req.Header.Set(constant.HeaderAccept, accept)
+		rw = httptest.NewRecorder()
+		assert.NotPanics(t, func() { router.ServeHTTP(rw, req) }, accept)
+		assert.Equal(t, contentType, rw.Header().Get(constant.HeaderContentType), accept)
+		if contentType == "" {
+			assert.Equal(t, http.StatusNotAcceptable, rw.Code, accept)
+		}
+	}
+}
+
+func TestBinaryRender(t *testing.T) {
+	router := fwncs.New()
+	router.GET("/xml", func(c fwncs.Context) {
+		c.XML(http.StatusOK, negotiateBody{Message: "hello"})
+	})
+	router.GET("/msgpack", func(c fwncs.Context) {
+		c.MsgPack(http.StatusCreated, negotiateBody{Message: "hello"})
+	})
+	router.GET("/protobuf", func(c fwncs.Context) {
+		c.ProtoBuf(http.StatusOK, wrapperspb.String("hello"))
+	})
+	router.GET("/negotiate", func(c fwncs.Context) {
+		c.Negotiate(http.StatusOK, fwncs.Negotiation{Data: wrapperspb.String("hello")})
+	})
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(constant.HeaderAccept, accept)
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	tt := tests.TestFrames{
+		{Name: "xml", Fn: func(t *testing.T) {
+			rw := serve("/xml", "")
+			assert.Equal(t, constant.XML.String(), rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, "<negotiateBody><message>hello</message></negotiateBody>", rw.Body.String())
+		}},
+		{Name: "msgpack", Fn: func(t *testing.T) {
+			rw := serve("/msgpack", "")
+			assert.Equal(t, http.StatusCreated, rw.Code)
+			assert.Equal(t, constant.MSGPACK.String(), rw.Header().Get(constant.HeaderContentType))
+			var body map[string]string
+			assert.NoError(t, msgpack.Unmarshal(rw.Body.Bytes(), &body))
+			assert.Equal(t, map[string]string{"message": "hello"}, body)
+		}},
+		{Name: "protobuf", Fn: func(t *testing.T) {
+			for _, path := range []string{"/protobuf", "/negotiate"} {
+				rw := serve(path, constant.ProtocolBuffer.String())
+				assert.Equal(t, constant.ProtocolBuffer.String(), rw.Header().Get(constant.HeaderContentType), path)
+				var body wrapperspb.StringValue
+				assert.NoError(t, proto.Unmarshal(rw.Body.Bytes(), &body), path)
+				assert.Equal(t, "hello", body.GetValue(), path)
+			}
+		}},
+		{Name: "negotiate msgpack", Fn: func(t *testing.T) {
+			rw := serve("/negotiate", "application/msgpack")
+			assert.Equal(t, constant.MSGPACK.String(), rw.Header().Get(constant.HeaderContentType))
+		}},
+	}
+	tt.Run(t)
+}
+
+func TestJSONRender(t *testing.T) {
+	body := map[string]string{"message": "こんにちは😀 <b>&</b>"}
+	router := fwncs.New()
+	router.GET("/json", func(c fwncs.Context) {
+		c.JSON(http.StatusOK, body)
+	})
+	router.GET("/ascii", func(c fwncs.Context) {
+		c.AsciiJSON(http.StatusOK, body)
+	})
+	router.GET("/pure", func(c fwncs.Context) {
+		c.PureJSON(http.StatusOK, body)
+	})
+	router.GET("/secure/array", func(c fwncs.Context) {
+		c.SecureJSON(http.StatusOK, []string{"a", "b"})
+	})
+	router.GET("/secure/object", func(c fwncs.Context) {
+		c.SecureJSON(http.StatusOK, body)
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+	tt := tests.TestFrames{
+		{Name: "json escapes html", Fn: func(t *testing.T) {
+			rw := serve("/json")
+			assert.Equal(t, `{"message":"こんにちは😀 \u003cb\u003e\u0026\u003c/b\u003e"}`+"\n", rw.Body.String())
+		}},
+		{Name: "ascii", Fn: func(t *testing.T) {
+			rw := serve("/ascii")
+			assert.Equal(t, constant.JSONAscii.String(), rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, `{"message":"\u3053\u3093\u306b\u3061\u306f\ud83d\ude00 \u003cb\u003e\u0026\u003c/b\u003e"}`, rw.Body.String())
+			var decoded map[string]string
+			assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &decoded))
+			assert.Equal(t, body, decoded)
+		}},
+		{Name: "pure", Fn: func(t *testing.T) {
+			rw := serve("/pure")
+			assert.Equal(t, constant.JSON.String(), rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, `{"message":"こんにちは😀 <b>&</b>"}`+"\n", rw.Body.String())
+		}},
+		{Name: "secure", Fn: func(t *testing.T) {
+			rw := serve("/secure/array")
+			assert.Equal(t, `while(1);["a","b"]`, rw.Body.String())
+			rw = serve("/secure/object")
+			assert.True(t, strings.HasPrefix(rw.Body.String(), `{"message"`))
+
+			router.SecureJSONPrefix = ")]}',\n"
+			rw = serve("/secure/array")
+			assert.Equal(t, ")]}',\n[\"a\",\"b\"]", rw.Body.String())
+		}},
+	}
+	tt.Run(t)
+}
+
+func TestTypedAccessors(t *testing.T) {
+	router := fwncs.New()
+	router.POST("/users/:id", func(c fwncs.Context) {
+		id, err := c.ParamInt("id")
+		assert.NoError(t, err)
+		assert.Equal(t, 10, id)
+		limit, err := c.QueryInt64("limit")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(20), limit)
+		active, err := c.QueryBool("active")
+		assert.NoError(t, err)
+		assert.True(t, active)
+		since, err := c.QueryTime("since", "2006-01-02")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), since)
+		assert.Equal(t, []string{"a", "b"}, c.QueryArray("tag"))
+		assert.Equal(t, map[string]string{"name": "x", "age": "3"}, c.QueryMap("filter"))
+		assert.Equal(t, []string{"1", "2"}, c.PostFormArray("ids"))
+
+		_, err = c.QueryInt("missing")
+		var paramErr *fwncs.ParamError
+		if assert.True(t, errors.As(err, &paramErr)) {
+			assert.True(t, errors.Is(err, fwncs.ErrMissingParameter))
+			assert.Equal(t, http.StatusBadRequest, paramErr.Status())
+		}
+		_, err = c.QueryInt("tag")
+		if assert.True(t, errors.As(err, &paramErr)) {
+			assert.Equal(t, "query", paramErr.Source)
+			assert.Equal(t, "a", paramErr.Value)
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	req := httptest.NewRequest(http.MethodPost, "/users/10?limit=20&active=true&since=2021-06-01&tag=a&tag=b&filter[name]=x&filter[age]=3", strings.NewReader("ids=1&ids=2"))
+	req.Header.Set(constant.HeaderContentType, constant.POSTForm.String())
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestBodyCache(t *testing.T) {
+	router := fwncs.New()
+	router.MaxBodySize = 32
+	router.Use(func(c fwncs.Context) {
+		body, err := c.Body()
+		if err != nil {
+			return
+		}
+		c.Set("signature", string(body))
+		c.Next()
+	})
+	router.POST("/", func(c fwncs.Context) {
+		var req bindRequest
+		if err := c.Bind(&req); err != nil {
+			c.AbortWithBindError(err)
+			return
+		}
+		body, _ := c.Body()
+		assert.Equal(t, c.Get("signature"), string(body))
+		c.String(http.StatusOK, req.Name)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"cached"}`))
+	req.Header.Set(constant.HeaderContentType, constant.JSON.String())
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "cached", rw.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"`+strings.Repeat("x", 32)+`"}`))
+	req.Header.Set(constant.HeaderContentType, constant.JSON.String())
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+
+	router = fwncs.New()
+	router.MaxBodySize = 64
+	router.POST("/multipart", func(c fwncs.Context) {
+		if _, err := c.MultiPartForm(); err != nil {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	_ = mw.WriteField("name", strings.Repeat("x", 128))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/multipart", buf)
+	req.Header.Set(constant.HeaderContentType, mw.FormDataContentType())
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+
+	// Bind も Router.MaxMultipartMemory を超えたファイルは一時ファイルに保存する
+	router = fwncs.New()
+	router.MaxMultipartMemory = 16
+	router.POST("/multipart", func(c fwncs.Context) {
+		var req struct {
+			Name string `form:"name"`
+		}
+		if err := c.Bind(&req); err != nil {
+			c.AbortWithBindError(err)
+			return
+		}
+		f, err := c.Request().MultipartForm.File["file"][0].Open()
+		if assert.NoError(t, err) {
+			defer f.Close()
+			assert.IsType(t, &os.File{}, f)
+		}
+		c.String(http.StatusOK, req.Name)
+	})
+	buf = &bytes.Buffer{}
+	mw = multipart.NewWriter(buf)
+	_ = mw.WriteField("name", "alice")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	_, _ = fw.Write([]byte(strings.Repeat("x", 1024)))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/multipart", buf)
+	req.Header.Set(constant.HeaderContentType, mw.FormDataContentType())
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "alice", rw.Body.String())
+}
+
+func TestTrustedProxies(t *testing.T) {
+	router := fwncs.New()
+	assert.Error(t, router.SetTrustedProxies("10.0.0.0/33"))
+	assert.Error(t, router.SetTrustedProxies("proxy.local"))
+	router.GET("/", func(c fwncs.Context) {
+		c.String(http.StatusOK, "%s %s", c.ClientIP(), c.Scheme())
+	})
+	serve := func(remoteAddr string, header map[string]string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw.Body.String()
+	}
+	spoofed := map[string]string{
+		constant.HeaderXForwardedFor:   "1.1.1.1",
+		constant.HeaderXRealIP:         "2.2.2.2",
+		constant.HeaderXForwardedProto: "https",
+	}
+	tt := tests.TestFrames{
+		{Name: "untrusted by default", Fn: func(t *testing.T) {
+			assert.Equal(t, "203.0.113.10 http", serve("203.0.113.10:1234", spoofed))
+		}},
+		{Name: "walk right to left", Fn: func(t *testing.T) {
+			assert.NoError(t, router.SetTrustedProxies("10.0.0.0/8", "192.168.1.1"))
+			header := map[string]string{
+				constant.HeaderXForwardedFor:   "1.1.1.1, 203.0.113.10, 192.168.1.1",
+				constant.HeaderXForwardedProto: "HTTPS",
+			}
+			assert.Equal(t, "203.0.113.10 https", serve("10.1.2.3:1234", header))
+			assert.Equal(t, "203.0.113.99 http", serve("203.0.113.99:1234", spoofed))
+		}},
+		{Name: "proto from the right", Fn: func(t *testing.T) {
+			// クライアントが送った X-Forwarded-Proto の右に各プロキシが追加する
+			header := map[string]string{
+				constant.HeaderXForwardedFor:   "1.1.1.1, 203.0.113.10, 192.168.1.1",
+				constant.HeaderXForwardedProto: "https, http, https",
+			}
+			assert.Equal(t, "203.0.113.10 http", serve("10.1.2.3:1234", header))
+			header[constant.HeaderXForwardedProto] = "javascript"
+			assert.Equal(t, "203.0.113.10 http", serve("10.1.2.3:1234", header))
+		}},
+		{Name: "all trusted", Fn: func(t *testing.T) {
+			header := map[string]string{
+				constant.HeaderXForwardedFor: "10.0.0.1, 10.0.0.2",
+			}
+			assert.Equal(t, "10.0.0.1 http", serve("10.0.0.3:1234", header))
+		}},
+		{Name: "invalid header falls back", Fn: func(t *testing.T) {
+			header := map[string]string{
+				constant.HeaderXForwardedFor: "unknown",
+				constant.HeaderXRealIP:       "198.51.100.1",
+				constant.HeaderXForwardedSsl: "on",
+			}
+			assert.Equal(t, "198.51.100.1 https", serve("10.0.0.3:1234", header))
+		}},
+		{Name: "trusted headers", Fn: func(t *testing.T) {
+			router.RemoteIPHeaders = []string{"CF-Connecting-IP"}
+			header := map[string]string{
+				constant.HeaderXForwardedFor: "1.1.1.1",
+				"CF-Connecting-IP":           "198.51.100.2",
+			}
+			assert.Equal(t, "198.51.100.2 http", serve("10.0.0.3:1234", header))
+		}},
+	}
+	tt.Run(t)
+}
+
+func TestForwarded(t *testing.T) {
+	header := http.Header{}
+	header.Add(constant.HeaderForwarded, `for="_gazonk", For="[2001:db8:cafe::17]:4711"`)
+	header.Add(constant.HeaderForwarded, `for=192.0.2.60;proto=HTTPS;by=203.0.113.43;host="example.com:8443", for=10.0.0.1`)
+	header.Add(constant.HeaderForwarded, `for="broken`)
+	elements := fwncs.ParseForwarded(header)
+	assert.Equal(t, []fwncs.ForwardedElement{
+		{For: "_gazonk"},
+		{For: "[2001:db8:cafe::17]:4711"},
+		{For: "192.0.2.60", By: "203.0.113.43", Host: "example.com:8443", Proto: "https"},
+		{For: "10.0.0.1"},
+	}, elements)
+	assert.Equal(t, `for=192.0.2.60;by=203.0.113.43;host="example.com:8443";proto=https`, elements[2].String())
+	assert.Equal(t, `for="[2001:db8::1]"`, fwncs.ForwardedElement{For: fwncs.ForwardedNode("2001:db8::1")}.String())
+
+	router := fwncs.New()
+	assert.NoError(t, router.SetTrustedProxies("10.0.0.0/8"))
+	router.GET("/", func(c fwncs.Context) {
+		c.String(http.StatusOK, "%s %s %s", c.ClientIP(), c.Scheme(), c.Host())
+	})
+	// X-Forwarded-For だけを追加するプロキシはクライアントが偽装した Forwarded をそのまま転送する
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Host = "internal.local"
+	req.Header.Set(constant.HeaderForwarded, "for=1.2.3.4;proto=https;host=evil.example")
+	req.Header.Set(constant.HeaderXForwardedFor, "203.0.113.9")
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "203.0.113.9 http internal.local", rw.Body.String())
+
+	router.TrustForwardedHeader()
+	router.TrustForwardedHeader()
+	assert.Equal(t, []string{constant.HeaderForwarded, constant.HeaderXForwardedFor, constant.HeaderXRealIP}, router.RemoteIPHeaders)
+	serve := func(remoteAddr, forwarded string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Host = "internal.local"
+		req.Header.Set(constant.HeaderForwarded, forwarded)
+		req.Header.Set(constant.HeaderXForwardedFor, "198.51.100.1")
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw.Body.String()
+	}
+	assert.Equal(t, "192.0.2.60 https example.com", serve("10.0.0.2:1234", `for=1.1.1.1;proto=http;host=evil.com, for=192.0.2.60;proto=https;host=example.com, for=10.0.0.1`))
+	assert.Equal(t, "2001:db8:cafe::17 http internal.local", serve("10.0.0.2:1234", `for="[2001:db8:cafe::17]:4711"`))
+	assert.Equal(t, "198.51.100.1 http internal.local", serve("10.0.0.2:1234", `for=unknown`))
+	// 難読化されたノードは信頼しないプロキシとして扱い、その左の要素は使わない
+	assert.Equal(t, "198.51.100.1 http example.com", serve("10.0.0.2:1234", `for=192.0.2.60;proto=https;host=evil.com, for=_hidden;proto=http;host=example.com, for=10.0.0.1`))
+	assert.Equal(t, "198.51.100.1 https example.com", serve("10.0.0.2:1234", `for=192.0.2.60;proto=http, for=unknown;proto=https;host=example.com`))
+	assert.Equal(t, "192.0.2.60 http internal.local", serve("10.0.0.2:1234", `for=192.0.2.60;proto=ftp`))
+	assert.Equal(t, "203.0.113.5 http internal.local", serve("203.0.113.5:1234", `for=192.0.2.60;proto=https;host=example.com`))
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestContextCopy(t *testing.T) {
+	out := &syncBuffer{}
+	router := fwncs.New(fwncs.LoggerOptions(fwncs.NewLogger(out, fwncs.FormatShort, fwncs.FormatDatetime)))
+	router.Use(fwncs.RequestID())
+	copied := make(chan fwncs.Context, 2)
+	done := make(chan struct{})
+	router.GET("/users/:id", func(c fwncs.Context) {
+		c.Set("user", "alice")
+		cp := c.Copy()
+		c.Set("user", "bob")
+		copied <- cp
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/panic", func(c fwncs.Context) {
+		c.Go(func(c fwncs.Context) {
+			defer close(done)
+			panic("background failure")
+		})
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1?q=x", nil)
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	// 同じ _context を再利用させる
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/2?q=y", nil))
+
+	cp := <-copied
+	assert.Equal(t, "1", cp.Param("id"))
+	assert.Equal(t, "x", cp.QueryParam("q"))
+	assert.Equal(t, "alice", cp.Get("user"))
+	assert.Equal(t, rw.Header().Get(constant.HeaderXRequestID), cp.GetRequestID())
+	assert.NoError(t, cp.GetContext().Err())
+	_, err := cp.Writer().Write([]byte("late"))
+	assert.ErrorIs(t, err, fwncs.ErrCopiedContext)
+	// Go の外で Copy した Context に Render しても panic しない
+	assert.NotPanics(t, func() {
+		cp.JSON(http.StatusOK, map[string]string{"late": "true"})
+		cp.String(http.StatusOK, "late")
+		cp.AbortWithStatus(http.StatusNoContent)
+	})
+	assert.Contains(t, out.String(), fwncs.ErrCopiedContext.Error())
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	<-done
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "background failure")
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, out.String(), "request_id="+rw.Header().Get(constant.HeaderXRequestID))
+}
+
+func TestContextAsContext(t *testing.T) {
+	type ctxKey struct{}
+	router := fwncs.New()
+	router.Use(fwncs.RequestID())
+	router.GET("/", func(c fwncs.Context) {
+		ctx, cancel := context.WithTimeout(c.GetContext(), time.Minute)
+		defer cancel()
+		c.SetContext(context.WithValue(ctx, ctxKey{}, "from context"))
+		c.Set("user", "alice")
+
+		var _ context.Context = c
+		deadline, ok := c.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+		assert.Equal(t, "from context", c.Value(ctxKey{}))
+		assert.Equal(t, "alice", c.Value("user"))
+		assert.Nil(t, c.Value("unknown"))
+		assert.Equal(t, c.Writer().Header().Get(constant.HeaderXRequestID), fwncs.FromRequestID(c))
+		assert.NoError(t, c.Err())
+
+		// c から派生した context の値は GetContext から参照し、c 自身は設定前の context の値を参照する
+		c.SetContext(context.WithValue(c, "nested", "value"))
+		assert.Equal(t, "value", c.GetContext().Value("nested"))
+		assert.Equal(t, "from context", c.GetContext().Value(ctxKey{}))
+		assert.Nil(t, c.Value("nested"))
+		assert.Equal(t, "from context", c.Value(ctxKey{}))
+		assert.True(t, deadline.Equal(func() time.Time { d, _ := c.Deadline(); return d }()))
+		d, ok := c.GetContext().Deadline()
+		assert.True(t, ok)
+		assert.True(t, deadline.Equal(d))
+
+		// SetRequest の場合も同じ
+		c.SetRequest(c.Request().WithContext(context.WithValue(c, "request", "value")))
+		assert.Equal(t, "value", c.GetContext().Value("request"))
+		assert.Equal(t, "alice", c.GetContext().Value("user"))
+		assert.Nil(t, c.Value("unknown"))
+
+		cancel()
+		<-c.Done()
+		assert.ErrorIs(t, c.Err(), context.Canceled)
+		assert.ErrorIs(t, c.GetContext().Err(), context.Canceled)
+		c.String(http.StatusOK, "ok")
+	})
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestContextDerivedTimeout(t *testing.T) {
+	router := fwncs.New()
+	router.GET("/", func(c fwncs.Context) {
+		// DB や HTTP クライアントに c を渡しても SetContext した期限で止まる
+		ctx, cancel := context.WithTimeout(c, 50*time.Millisecond)
+		defer cancel()
+		c.SetContext(ctx)
+		deadline, ok := c.Deadline()
+		assert.True(t, ok)
+		expected, _ := c.GetContext().Deadline()
+		assert.True(t, expected.Equal(deadline))
+		assert.NoError(t, c.Err())
+
+		// WithValue を重ねても期限とキャンセルは維持する
+		c.SetContext(context.WithValue(c, "key", "value"))
+		_, ok = c.Deadline()
+		assert.True(t, ok)
+		select {
+		case <-c.Done():
+			assert.ErrorIs(t, c.Err(), context.DeadlineExceeded)
+		case <-time.After(3 * time.Second):
+			t.Error("c.Done() did not fire after the derived deadline")
+		}
+
+		// c から派生したキャンセルも c に伝わる
+		ctx, cancel = context.WithCancel(c.Copy())
+		c.SetContext(ctx)
+		cancel()
+		<-c.Done()
+		assert.ErrorIs(t, c.Err(), context.Canceled)
+		c.AbortWithStatus(http.StatusNoContent)
+	})
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+}
+
+func TestContextAsContextConcurrent(t *testing.T) {
+	type ctxKey struct{}
+	router := fwncs.New()
+	router.GET("/", func(c fwncs.Context) {
+		ctx, cancel := context.WithCancel(c.GetContext())
+		c.SetContext(context.WithValue(ctx, ctxKey{}, "value"))
+		c.SetContext(context.WithValue(c, "derived", "value"))
+		var wg sync.WaitGroup
+		var mismatches int32
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					if c.Value(ctxKey{}) != "value" || c.Err() != nil || c.Done() == nil {
+						atomic.AddInt32(&mismatches, 1)
+					}
+					if c.GetContext().Value(ctxKey{}) != "value" {
+						atomic.AddInt32(&mismatches, 1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Zero(t, mismatches)
+		cancel()
+		assert.ErrorIs(t, c.Err(), context.Canceled)
+		c.AbortWithStatus(http.StatusNoContent)
+	})
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+}
@@ -0,0 +1,124 @@
+package fwncs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+// ErrCopiedContext is Copy した Context でレスポンスを書き込もうとした
+var ErrCopiedContext = errors.New("fwncs: cannot write response from a copied context")
+
+func (c *_context) Copy() Context {
+	rid := c.requestID()
+	req := c.req.Clone(detachedContext{parent: c.req.Context()})
+	if rid != "" && req.Header.Get(constant.HeaderXRequestID) == "" {
+		req.Header.Set(constant.HeaderXRequestID, rid)
+	}
+	params := make(Params, len(*c.params))
+	copy(params, *c.params)
+	query := make(url.Values, len(c.query))
+	for key, values := range c.query {
+		query[key] = append([]string{}, values...)
+	}
+	c.mu.Lock()
+	mp := make(map[string]interface{}, len(c.mp))
+	for key, value := range c.mp {
+		mp[key] = value
+	}
+	c.mu.Unlock()
+	cp := &_context{
+		router:     c.router,
+		w:          &copiedResponseWriter{header: c.w.Header().Clone(), status: c.w.Status(), size: c.w.Size()},
+		req:        req,
+		params:     &params,
+		logger:     c.logger,
+		skip:       true,
+		index:      -1,
+		mp:         mp,
+		errs:       append([]error{}, c.errs...),
+		mu:         sync.Mutex{},
+		query:      query,
+		path:       c.path,
+		method:     c.method,
+		_Params:    append(Params{}, c._Params...),
+		fullPath:   c.fullPath,
+		body:       c.body,
+		bodyCached: c.bodyCached,
+	}
+	cp.setParent(req.Context())
+	return cp
+}
+
+func (c *_context) Go(fn func(c Context)) {
+	cp := c.Copy()
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				cp.Logger().Error(fmt.Sprintf("panic in goroutine: request_id=%s, path=%s, %v\n%s", cp.GetRequestID(), cp.Request().URL.Path, rcv, debug.Stack()))
+			}
+		}()
+		fn(cp)
+	}()
+}
+
+// requestID is RequestID ミドルウェアが生成した ID を優先して返す
+func (c *_context) requestID() string {
+	if rid, ok := c.req.Context().Value(requestIDKey).(string); ok {
+		return rid
+	}
+	return c.GetRequestID()
+}
+
+// detachedContext is 値のみを引き継ぎ、キャンセルされない context
+// 	handler の終了時にリクエストの context はキャンセルされるため、goroutine ではこちらを使う
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// copiedResponseWriter is Copy した Context の ResponseWriter
+// 	ヘッダーとステータスは Copy した時点の値で、書き込みは全て ErrCopiedContext になる
+type copiedResponseWriter struct {
+	header http.Header
+	status int
+	size   int
+}
+
+var _ ResponseWriter = &copiedResponseWriter{}
+
+func (w *copiedResponseWriter) Header() http.Header { return w.header }
+func (w *copiedResponseWriter) WriteHeader(int)     {}
+func (w *copiedResponseWriter) WriteHeaderNow()     {}
+func (w *copiedResponseWriter) Flush()              {}
+func (w *copiedResponseWriter) Status() int         { return w.status }
+func (w *copiedResponseWriter) Size() int           { return w.size }
+func (w *copiedResponseWriter) Written() bool       { return true }
+func (w *copiedResponseWriter) Pusher() http.Pusher { return nil }
+
+func (w *copiedResponseWriter) Write([]byte) (int, error) {
+	return 0, ErrCopiedContext
+}
+
+func (w *copiedResponseWriter) WriteString(string) (int, error) {
+	return 0, ErrCopiedContext
+}
+
+func (w *copiedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, ErrCopiedContext
+}
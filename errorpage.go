@@ -0,0 +1,138 @@
+package fwncs
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/render"
+)
+
+const defaultErrorPageLanguage = "ja"
+
+var defaultErrorPageTemplate = template.Must(template.New("error").Parse(`
+<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+  <meta charset="UTF-8">
+  <meta http-equiv="X-UA-Compatible" content="IE=edge">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{.Message}}</title>
+</head>
+<body>
+  <p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+// ErrorPage is 404, 405, 500 などのデフォルトのエラーレスポンスの設定
+// 	Accept で HTML が優先される場合は HTML、それ以外は DefaultResponseBody の JSON を返す
+type ErrorPage struct {
+	// Template is ErrorPageData を渡して実行する HTML の template (nil の場合はデフォルトの template)
+	Template *template.Template
+	// Language is ErrorPageData.Language (空の場合は "ja")
+	Language string
+	// pages is ステータス毎の HTML (Template より優先する)
+	pages map[int][]byte
+}
+
+// ErrorPageData is ErrorPage.Template に渡すデータ
+type ErrorPageData struct {
+	Code     int
+	Message  string
+	Language string
+	Path     string
+}
+
+// SetPage is status のエラーページに html を使う
+func (e *ErrorPage) SetPage(status int, html []byte) {
+	if e.pages == nil {
+		e.pages = map[int][]byte{}
+	}
+	e.pages[status] = html
+}
+
+// SetPageFile is status のエラーページにファイルを使う
+func (e *ErrorPage) SetPageFile(status int, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	html, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	e.SetPage(status, html)
+	return nil
+}
+
+// SetPageFS is status のエラーページに fs のファイルを使う
+// 	embed.FS は http.FS で http.FileSystem に変換できる
+func (e *ErrorPage) SetPageFS(status int, fs http.FileSystem, name string) error {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	f, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	html, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	e.SetPage(status, html)
+	return nil
+}
+
+// render is HTML か JSON でエラーを返却する
+// 	Accept が無い場合は defaultFormat になる
+func (e *ErrorPage) render(c Context, code int, defaultFormat string) {
+	offers := []string{constant.HTML.String(), constant.JSONAscii.String()}
+	if defaultFormat == constant.JSONAscii.String() {
+		offers[0], offers[1] = offers[1], offers[0]
+	}
+	if c.NegotiateFormat(offers...) != constant.HTML.String() {
+		c.JSON(code, NewDefaultResponseBody(code, http.StatusText(code)))
+		return
+	}
+	if html, ok := e.pages[code]; ok {
+		c.Render(code, render.Data{ContentType: constant.HTML.String(), Data: html})
+		return
+	}
+	tmpl := e.Template
+	if tmpl == nil {
+		tmpl = defaultErrorPageTemplate
+	}
+	language := e.Language
+	if language == "" {
+		language = defaultErrorPageLanguage
+	}
+	c.Render(code, render.TemplateRender{
+		Template: tmpl,
+		Data: ErrorPageData{
+			Code:     code,
+			Message:  http.StatusText(code),
+			Language: language,
+			Path:     c.Request().URL.Path,
+		},
+	})
+}
+
+// prefersHTMLErrorPage is Accept で JSON より HTML が優先されるかを返す
+func prefersHTMLErrorPage(c Context) bool {
+	return c.NegotiateFormat(constant.JSONAscii.String(), constant.HTML.String()) == constant.HTML.String()
+}
+
+// renderErrorPage is Router.ErrorPage でエラーを返却する
+func renderErrorPage(c Context, code int, defaultFormat string) {
+	if cc, ok := c.(*_context); ok {
+		cc.router.ErrorPage.render(c, code, defaultFormat)
+		return
+	}
+	(&ErrorPage{}).render(c, code, defaultFormat)
+}
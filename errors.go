@@ -0,0 +1,185 @@
+package fwncs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs/binding"
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+// HTTPError is クライアントへ返却するエラー
+// 	DefaultResponseBody の Code に HTTP ステータス、ErrorCode にアプリケーション固有のエラーコードを持つ
+// 	Internal はログにのみ出力し、レスポンスには含めない
+type HTTPError struct {
+	DefaultResponseBody
+	ErrorCode string      `json:"error_code,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{
+		DefaultResponseBody: *NewDefaultResponseBody(status, message),
+	}
+}
+
+// WithCode is アプリケーション固有のエラーコードを設定する
+func (e *HTTPError) WithCode(code string) *HTTPError {
+	e.ErrorCode = code
+	return e
+}
+
+// WithInternal is 原因となったエラーを設定する
+func (e *HTTPError) WithInternal(err error) *HTTPError {
+	e.Internal = err
+	return e
+}
+
+// WithDetails is 検証エラーの一覧などの詳細を設定する
+func (e *HTTPError) WithDetails(details interface{}) *HTTPError {
+	e.Details = details
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("code=%d, message=%v", e.Code, e.Message)
+	if e.ErrorCode != "" {
+		msg += ", error_code=" + e.ErrorCode
+	}
+	if e.Internal != nil {
+		msg += fmt.Sprintf(", internal=%v", e.Internal)
+	}
+	return msg
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Internal
+}
+
+func (e *HTTPError) StatusCode() int {
+	return e.Code
+}
+
+// BindError is request body などを読み込めなかった際のエラー (JSON の構文エラーや型の不一致など)
+// 	呼び出し元の入力不備なので Status は 400 を返し、メッセージに Err の内容を含める
+type BindError struct {
+	Err error
+}
+
+func (e *BindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BindError) Status() int {
+	return http.StatusBadRequest
+}
+
+// bindError is ToHTTPError で 500 になるエラーを BindError にする
+// 	検証エラーや 413, 415 のエラーはそのまま返す
+func bindError(err error) error {
+	if err == nil || ToHTTPError(err).Code != http.StatusInternalServerError {
+		return err
+	}
+	return &BindError{Err: err}
+}
+
+// ErrorHandlerFunc is handler チェーンが何も書き込まずにエラーを残して終了した場合に呼び出される
+type ErrorHandlerFunc func(c Context, errs []error)
+
+// DefaultErrorHandler is 最初のエラーを HTTPError の JSON で返却する
+// 	5xx の場合は原因をログに出力する
+// 	Accept で HTML が優先される場合は Router.ErrorPage のエラーページを返す
+// 	Router.ProblemDetails が有効な場合は ProblemErrorHandler と同じ
+func DefaultErrorHandler(c Context, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	if useProblemDetails(c) {
+		ProblemErrorHandler(c, errs)
+		return
+	}
+	he := contextHTTPError(c, errs[0])
+	if he.Code >= http.StatusInternalServerError {
+		for _, err := range errs {
+			c.Logger().Error(err)
+		}
+	}
+	if prefersHTMLErrorPage(c) {
+		renderErrorPage(c, he.Code, constant.JSONAscii.String())
+		return
+	}
+	c.JSON(he.Code, he)
+}
+
+// ToHTTPError is err を HTTPError に変換する
+// 	HTTPError, DefaultResponseBody, Status() int を持つエラー (ParamError など) はそのステータスになる
+// 	それ以外は内部エラーとして 500 になり、エラーの内容はレスポンスに含めない
+func ToHTTPError(err error) *HTTPError {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	var body *DefaultResponseBody
+	if errors.As(err, &body) {
+		return &HTTPError{DefaultResponseBody: *body}
+	}
+	var withStatus interface{ Status() int }
+	if errors.As(err, &withStatus) {
+		return NewHTTPError(withStatus.Status(), err.Error()).WithInternal(err)
+	}
+	if _, ok := binding.TranslateError(err); ok {
+		return NewHTTPError(http.StatusBadRequest, "").WithInternal(err)
+	}
+	return NewHTTPError(http.StatusInternalServerError, "").WithInternal(err)
+}
+
+// contextHTTPError is 検証エラーの場合は Accept-Language で翻訳した一覧を Details に設定する
+func contextHTTPError(c Context, err error) *HTTPError {
+	he := ToHTTPError(err)
+	if he.Details == nil {
+		if he.Internal != nil {
+			err = he.Internal
+		}
+		locales := binding.ParseAcceptLanguage(c.Header().Get(constant.HeaderAcceptLanguage))
+		if errs, ok := binding.TranslateError(err, locales...); ok {
+			copied := *he
+			copied.Details = errs
+			he = &copied
+		}
+	}
+	return he
+}
+
+func (c *_context) AbortWithError(status int, err error) {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		message := ""
+		if status < http.StatusInternalServerError {
+			message = err.Error()
+		}
+		he = NewHTTPError(status, message).WithInternal(err)
+	}
+	c.Error(he)
+	c.Skip()
+}
+
+// handleErrors is 何も書き込まれていない場合に残ったエラーを Router.ErrorHandler で返却する
+// 	Logger などの前段のミドルウェアが結果を参照できるよう、handler チェーンの終了時 (Next) に呼び出す
+func (c *_context) handleErrors() {
+	if len(c.errs) == 0 || c.w.Written() {
+		return
+	}
+	handler := c.router.ErrorHandler
+	if handler == nil {
+		handler = DefaultErrorHandler
+	}
+	handler(c, c.errs)
+}
@@ -0,0 +1,381 @@
+package fwncs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+	serve := func(router *fwncs.Router, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(constant.HeaderContentType, constant.JSON.String())
+		req.Header.Set(constant.HeaderAcceptLanguage, "ja")
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	tt := tests.TestFrames{
+		{
+			Name: "http error",
+			Fn: func(t *testing.T) {
+				status := 0
+				router := fwncs.New()
+				router.Use(func(c fwncs.Context) {
+					c.Next()
+					status = c.GetStatus()
+				})
+				router.GET("/users/:id", func(c fwncs.Context) {
+					c.Error(fwncs.NewHTTPError(http.StatusNotFound, "user not found").WithCode("USER_NOT_FOUND").WithInternal(errors.New("sql: no rows")))
+				})
+				rw := serve(router, http.MethodGet, "/users/1", "")
+				assert.Equal(t, http.StatusNotFound, rw.Code)
+				assert.Equal(t, http.StatusNotFound, status)
+				assert.JSONEq(t, `{"code":404,"status":"error","message":"user not found","error_code":"USER_NOT_FOUND"}`, rw.Body.String())
+			},
+		},
+		{
+			Name: "abort with error",
+			Fn: func(t *testing.T) {
+				router := fwncs.New()
+				router.Use(func(c fwncs.Context) {
+					if c.QueryParam("deny") != "" {
+						c.AbortWithError(http.StatusForbidden, errors.New("denied"))
+						return
+					}
+					c.Next()
+				})
+				router.GET("/", func(c fwncs.Context) {
+					c.String(http.StatusOK, "ok")
+				})
+				router.GET("/internal", func(c fwncs.Context) {
+					c.Error(errors.New("database is down"))
+				})
+				router.GET("/param", func(c fwncs.Context) {
+					if _, err := c.QueryInt("page"); err != nil {
+						c.Error(err)
+					}
+				})
+				rw := serve(router, http.MethodGet, "/?deny=1", "")
+				assert.Equal(t, http.StatusForbidden, rw.Code)
+				assert.JSONEq(t, `{"code":403,"status":"error","message":"denied"}`, rw.Body.String())
+
+				rw = serve(router, http.MethodGet, "/internal", "")
+				assert.Equal(t, http.StatusInternalServerError, rw.Code)
+				assert.NotContains(t, rw.Body.String(), "database")
+
+				rw = serve(router, http.MethodGet, "/param?page=x", "")
+				assert.Equal(t, http.StatusBadRequest, rw.Code)
+				assert.Contains(t, rw.Body.String(), "page")
+			},
+		},
+		{
+			Name: "validation error details",
+			Fn: func(t *testing.T) {
+				router := fwncs.New()
+				router.POST("/", func(c fwncs.Context) {
+					var req bindRequest
+					if err := c.Bind(&req); err != nil {
+						c.AbortWithError(http.StatusBadRequest, err)
+						return
+					}
+				})
+				rw := serve(router, http.MethodPost, "/", "{}")
+				assert.Equal(t, http.StatusBadRequest, rw.Code)
+				var body struct {
+					Details []map[string]string `json:"details"`
+				}
+				assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+				if assert.Len(t, body.Details, 1) {
+					assert.Equal(t, "nameは必須フィールドです", body.Details[0]["message"])
+				}
+			},
+		},
+		{
+			Name: "custom error handler",
+			Fn: func(t *testing.T) {
+				router := fwncs.New()
+				router.ErrorHandler = func(c fwncs.Context, errs []error) {
+					c.String(fwncs.ToHTTPError(errs[0]).Code, "custom: %d errors", len(errs))
+				}
+				router.GET("/", func(c fwncs.Context) {
+					c.Error(errors.New("first"))
+					c.Error(errors.New("second"))
+				})
+				rw := serve(router, http.MethodGet, "/", "")
+				assert.Equal(t, http.StatusInternalServerError, rw.Code)
+				assert.Equal(t, "custom: 2 errors", rw.Body.String())
+			},
+		},
+	}
+	tt.Run(t)
+}
+
+func TestProblemDetails(t *testing.T) {
+	router := fwncs.New()
+	router.ProblemDetails = true
+	router.Use(fwncs.Recovery())
+	router.GET("/conflict", func(c fwncs.Context) {
+		c.Error(fwncs.NewHTTPError(http.StatusConflict, "already exists").WithCode("DUPLICATE"))
+	})
+	router.GET("/panic", func(c fwncs.Context) {
+		panic("secret")
+	})
+	router.GET("/unauthorized", func(c fwncs.Context) {
+		c.AbortWithStatusAndErrorMessage(http.StatusUnauthorized, errors.New("token required"))
+	})
+	router.GET("/forbidden", func(c fwncs.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})
+	router.GET("/custom", func(c fwncs.Context) {
+		c.AbortWithProblem(fwncs.NewProblem(http.StatusPaymentRequired, "insufficient credit").
+			WithType("https://example.com/probs/out-of-credit", "You do not have enough credit.").
+			WithInstance("/account/12345").
+			With("balance", 30))
+	})
+	tt := tests.TestFrames{
+		{Name: "http error", Fn: func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/conflict", nil))
+			assert.Equal(t, http.StatusConflict, rw.Code)
+			assert.Equal(t, constant.ProblemJSON.String(), rw.Header().Get(constant.HeaderContentType))
+			assert.JSONEq(t, `{"type":"about:blank","title":"Conflict","status":409,"detail":"already exists","instance":"/conflict","error_code":"DUPLICATE"}`, rw.Body.String())
+		}},
+		{Name: "recovery", Fn: func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/panic", nil))
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+			assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500}`, rw.Body.String())
+		}},
+		{Name: "abort", Fn: func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/unauthorized", nil))
+			assert.Equal(t, http.StatusUnauthorized, rw.Code)
+			assert.JSONEq(t, `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"token required"}`, rw.Body.String())
+
+			rw = httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/forbidden", nil))
+			assert.Equal(t, http.StatusForbidden, rw.Code)
+			assert.JSONEq(t, `{"type":"about:blank","title":"Forbidden","status":403}`, rw.Body.String())
+		}},
+		{Name: "not found and method not allowed", Fn: func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/missing", nil))
+			assert.Equal(t, http.StatusNotFound, rw.Code)
+			assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"instance":"/missing"}`, rw.Body.String())
+
+			rw = httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/conflict", nil))
+			assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+			assert.Equal(t, constant.ProblemJSON.String(), rw.Header().Get(constant.HeaderContentType))
+		}},
+		{Name: "extension members", Fn: func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/custom", nil))
+			assert.Equal(t, http.StatusPaymentRequired, rw.Code)
+			var p fwncs.Problem
+			assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &p))
+			assert.Equal(t, "https://example.com/probs/out-of-credit", p.Type)
+			assert.Equal(t, "You do not have enough credit.", p.Title)
+			assert.Equal(t, "/account/12345", p.Instance)
+			assert.Equal(t, float64(30), p.Extensions["balance"])
+		}},
+	}
+	tt.Run(t)
+}
+
+func TestErrorPage(t *testing.T) {
+	serve := func(router *fwncs.Router, method, path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if accept != "" {
+			req.Header.Set(constant.HeaderAccept, accept)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	tt := tests.TestFrames{
+		{Name: "default", Fn: func(t *testing.T) {
+			router := fwncs.New()
+			rw := serve(router, http.MethodGet, "/missing", "")
+			assert.Equal(t, http.StatusNotFound, rw.Code)
+			assert.Equal(t, constant.HTML.String(), rw.Header().Get(constant.HeaderContentType))
+			assert.Contains(t, rw.Body.String(), `<html lang="ja">`)
+			assert.Contains(t, rw.Body.String(), "<title>Not Found</title>")
+
+			rw = serve(router, http.MethodGet, "/missing", "application/json")
+			assert.Equal(t, http.StatusNotFound, rw.Code)
+			assert.JSONEq(t, `{"code":404,"status":"error","message":"Not Found"}`, rw.Body.String())
+		}},
+		{Name: "template and language", Fn: func(t *testing.T) {
+			router := fwncs.New()
+			router.ErrorPage.Language = "en"
+			router.ErrorPage.Template = template.Must(template.New("error").Parse(`<html lang="{{.Language}}">{{.Code}} {{.Message}} {{.Path}}</html>`))
+			rw := serve(router, http.MethodGet, "/missing", browser)
+			assert.Equal(t, http.StatusNotFound, rw.Code)
+			assert.Equal(t, `<html lang="en">404 Not Found /missing</html>`, rw.Body.String())
+		}},
+		{Name: "page per status", Fn: func(t *testing.T) {
+			fs := fstest.MapFS{
+				"errors/405.html": {Data: []byte("<p>method not allowed</p>")},
+				"errors/500.html": {Data: []byte("<p>sorry</p>")},
+			}
+			router := fwncs.New()
+			assert.NoError(t, router.ErrorPage.SetPageFS(http.StatusMethodNotAllowed, http.FS(fs), "errors/405.html"))
+			assert.NoError(t, router.ErrorPage.SetPageFS(http.StatusInternalServerError, http.FS(fs), "errors/500.html"))
+			assert.Error(t, router.ErrorPage.SetPageFS(http.StatusNotFound, http.FS(fs), "errors/404.html"))
+			router.GET("/", func(c fwncs.Context) {
+				c.Error(errors.New("database is down"))
+			})
+			rw := serve(router, http.MethodPost, "/", browser)
+			assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+			assert.Equal(t, "<p>method not allowed</p>", rw.Body.String())
+
+			rw = serve(router, http.MethodGet, "/", browser)
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+			assert.Equal(t, "<p>sorry</p>", rw.Body.String())
+
+			rw = serve(router, http.MethodGet, "/", "")
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+			assert.Equal(t, constant.JSON.String(), rw.Header().Get(constant.HeaderContentType))
+		}},
+	}
+	tt.Run(t)
+}
+
+func TestHandlerE(t *testing.T) {
+	var logged []error
+	router := fwncs.New()
+	router.Use(func(c fwncs.Context) {
+		c.Next()
+		logged = c.GetError()
+	})
+	auth := func(c fwncs.Context) error {
+		if c.Header().Get(constant.HeaderAuthorization) == "" {
+			return fwncs.NewHTTPError(http.StatusUnauthorized, "")
+		}
+		return nil
+	}
+	router.GETE("/users/:id", auth, func(c fwncs.Context) error {
+		id, err := c.ParamInt("id")
+		if err != nil {
+			return err
+		}
+		if id != 1 {
+			return fwncs.NewHTTPError(http.StatusNotFound, "user not found")
+		}
+		c.JSON(http.StatusOK, map[string]int{"id": id})
+		return nil
+	})
+	router.GET("/wrapped", fwncs.E(func(c fwncs.Context) error {
+		return errors.New("unexpected")
+	}))
+	router.POSTE("/users", func(c fwncs.Context) error {
+		var req bindRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		c.String(http.StatusCreated, req.Name)
+		return nil
+	})
+	router.HEADE("/users/:id", auth, func(c fwncs.Context) error {
+		c.SetHeader("X-User", c.Param("id"))
+		c.AbortWithStatus(http.StatusOK)
+		return nil
+	})
+	router.OPTIONSE("/users", func(c fwncs.Context) error {
+		return fwncs.NewHTTPError(http.StatusForbidden, "")
+	})
+	serve := func(path string, authorized bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorized {
+			req.Header.Set(constant.HeaderAuthorization, "Bearer token")
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	tt := tests.TestFrames{
+		{Name: "success", Fn: func(t *testing.T) {
+			rw := serve("/users/1", true)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.JSONEq(t, `{"id":1}`, rw.Body.String())
+			assert.Empty(t, logged)
+		}},
+		{Name: "middleware error stops the chain", Fn: func(t *testing.T) {
+			rw := serve("/users/1", false)
+			assert.Equal(t, http.StatusUnauthorized, rw.Code)
+			assert.Len(t, logged, 1)
+		}},
+		{Name: "handler error", Fn: func(t *testing.T) {
+			rw := serve("/users/2", true)
+			assert.Equal(t, http.StatusNotFound, rw.Code)
+			assert.JSONEq(t, `{"code":404,"status":"error","message":"user not found"}`, rw.Body.String())
+
+			rw = serve("/users/x", true)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			var paramErr *fwncs.ParamError
+			if assert.Len(t, logged, 1) {
+				assert.True(t, errors.As(logged[0], &paramErr))
+			}
+
+			rw = serve("/wrapped", false)
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		}},
+		{Name: "head and options", Fn: func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodHead, "/users/1", nil)
+			req.Header.Set(constant.HeaderAuthorization, "Bearer token")
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, "1", rw.Header().Get("X-User"))
+
+			rw = httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodHead, "/users/1", nil))
+			assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+			rw = httptest.NewRecorder()
+			router.ServeHTTP(rw, httptest.NewRequest(http.MethodOptions, "/users", nil))
+			assert.Equal(t, http.StatusForbidden, rw.Code)
+			assert.Len(t, logged, 1)
+		}},
+		{Name: "bind error", Fn: func(t *testing.T) {
+			post := func(body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+				req.Header.Set(constant.HeaderContentType, constant.JSON.String())
+				rw := httptest.NewRecorder()
+				router.ServeHTTP(rw, req)
+				return rw
+			}
+			rw := post(`{"name":"taro"}`)
+			assert.Equal(t, http.StatusCreated, rw.Code)
+
+			rw = post(`{"name":`)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			var bindErr *fwncs.BindError
+			if assert.Len(t, logged, 1) {
+				assert.True(t, errors.As(logged[0], &bindErr))
+			}
+
+			rw = post(`{"name":1}`)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Contains(t, rw.Body.String(), "cannot unmarshal number")
+
+			rw = post(`{"email":"taro"}`)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Contains(t, rw.Body.String(), `"field":"name"`)
+		}},
+	}
+	tt.Run(t)
+}
@@ -1 +1,188 @@
 package fwncs
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/render"
+)
+
+func (c *_context) File(name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		c.abortWithFileError(err)
+		return
+	}
+	defer f.Close()
+	c.serveFile(f)
+}
+
+func (c *_context) FileFromFS(name string, fs http.FileSystem) {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	f, err := fs.Open(name)
+	if err != nil {
+		c.abortWithFileError(err)
+		return
+	}
+	defer f.Close()
+	c.serveFile(f)
+}
+
+func (c *_context) Attachment(name, filename string) {
+	c.SetHeader(constant.HeaderContentDisposition, ContentDisposition("attachment", filename))
+	c.File(name)
+}
+
+func (c *_context) CSV(status int, filename string, rows interface{}) {
+	var r render.Render
+	switch rows := rows.(type) {
+	case render.CSV:
+		r = rows
+	case render.CSVStream:
+		if rows.Context == nil {
+			rows.Context = c.GetContext()
+		}
+		r = rows
+	default:
+		options := render.CSVOptions{}
+		if strings.EqualFold(filepath.Ext(filename), ".tsv") {
+			options.Comma = '\t'
+		}
+		switch reflect.ValueOf(rows).Kind() {
+		case reflect.Slice, reflect.Array:
+			r = render.CSV{CSVOptions: options, Rows: rows}
+		default:
+			r = render.CSVStream{CSVOptions: options, Context: c.GetContext(), Items: rows, FlushInterval: c.router.StreamFlushInterval}
+		}
+	}
+	if filename != "" {
+		c.SetHeader(constant.HeaderContentDisposition, ContentDisposition("attachment", filename))
+	}
+	c.renderStream(status, r)
+}
+
+func (c *_context) DataFromReader(status int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	header := c.Writer().Header()
+	for key, value := range extraHeaders {
+		header.Set(key, value)
+	}
+	if contentType != "" {
+		header.Set(constant.HeaderContentType, contentType)
+	}
+	// Seek できる場合は Range, If-Range, If-None-Match, If-Modified-Since に対応する
+	if rs, ok := reader.(io.ReadSeeker); ok && status == http.StatusOK {
+		modtime, _ := http.ParseTime(header.Get(constant.HeaderLastModified))
+		http.ServeContent(c.Writer(), c.Request(), "", modtime, rs)
+		return
+	}
+	if contentLength >= 0 {
+		header.Set(constant.HeaderContentLength, strconv.FormatInt(contentLength, 10))
+	}
+	c.SetStatus(status)
+	c.Writer().WriteHeaderNow()
+	if !bodyAllowedForStatus(status) || c.Request().Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(c.Writer(), reader); err != nil {
+		c.Error(err)
+	}
+}
+
+// serveFile is ETag を付与して http.ServeContent で返却する
+// 	Range, If-Range, If-None-Match, If-Modified-Since は http.ServeContent が処理する
+func (c *_context) serveFile(f http.File) {
+	d, err := f.Stat()
+	if err != nil {
+		c.abortWithFileError(err)
+		return
+	}
+	if d.IsDir() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	header := c.Writer().Header()
+	if header.Get(constant.HeaderETag) == "" {
+		header.Set(constant.HeaderETag, fileETag(d.Name(), d.Size(), d.ModTime()))
+	}
+	http.ServeContent(c.Writer(), c.Request(), d.Name(), d.ModTime(), f)
+}
+
+func (c *_context) abortWithFileError(err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		c.AbortWithStatus(http.StatusNotFound)
+	case errors.Is(err, os.ErrPermission):
+		c.AbortWithStatus(http.StatusForbidden)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// fileETag is 更新日時、サイズ、ファイル名のハッシュから強い ETag を作る
+// 	弱い ETag は If-Range で一致しないため Range リクエストの再開に使えない
+func fileETag(name string, size int64, modtime time.Time) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return fmt.Sprintf(`"%x-%x-%x"`, modtime.UnixNano(), size, h.Sum32())
+}
+
+// ContentDisposition is RFC 6266 形式の Content-Disposition を返す
+// 	ASCII 以外を含むファイル名は filename* (RFC 5987 UTF-8 エンコード) を付与し、filename には代替の ASCII 名を設定する
+func ContentDisposition(dispositionType, filename string) string {
+	filename = filepath.Base(filename)
+	fallback := asciiFilename(filename)
+	if fallback == filename {
+		return fmt.Sprintf(`%s; filename="%s"`, dispositionType, fallback)
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, fallback, encodeRFC5987(filename))
+}
+
+func asciiFilename(filename string) string {
+	b := &strings.Builder{}
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\' || r < 0x20 || r > 0x7e:
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// encodeRFC5987 is attr-char 以外をパーセントエンコードする
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if isAttrChar(ch) {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0f])
+	}
+	return b.String()
+}
+
+func isAttrChar(ch byte) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", ch) >= 0
+}
@@ -0,0 +1,205 @@
+package fwncs_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/render"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestFileResponses(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "report.txt")
+	assert.NoError(t, ioutil.WriteFile(name, []byte("0123456789"), 0644))
+
+	router := fwncs.New()
+	router.GET("/file", func(c fwncs.Context) {
+		c.File(name)
+	})
+	router.GET("/fs/*filepath", func(c fwncs.Context) {
+		c.FileFromFS(c.Param("filepath"), http.Dir(dir))
+	})
+	router.GET("/attachment", func(c fwncs.Context) {
+		c.Attachment(name, "売上レポート 2021.txt")
+	})
+	router.GET("/reader", func(c fwncs.Context) {
+		c.DataFromReader(http.StatusOK, 10, "text/plain", strings.NewReader("abcdefghij"), map[string]string{
+			constant.HeaderETag: `"reader"`,
+		})
+	})
+	serve := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	tt := tests.TestFrames{
+		{
+			Name: "file with etag and range",
+			Fn: func(t *testing.T) {
+				rw := serve("/file", nil)
+				assert.Equal(t, http.StatusOK, rw.Code)
+				assert.Equal(t, "0123456789", rw.Body.String())
+				etag := rw.Header().Get(constant.HeaderETag)
+				assert.NotEmpty(t, etag)
+				assert.NotEmpty(t, rw.Header().Get(constant.HeaderLastModified))
+
+				rw = serve("/file", map[string]string{"If-None-Match": etag})
+				assert.Equal(t, http.StatusNotModified, rw.Code)
+
+				rw = serve("/file", map[string]string{"Range": "bytes=2-4"})
+				assert.Equal(t, http.StatusPartialContent, rw.Code)
+				assert.Equal(t, "234", rw.Body.String())
+
+				assert.False(t, strings.HasPrefix(etag, "W/"))
+				rw = serve("/file", map[string]string{"Range": "bytes=2-4", "If-Range": etag})
+				assert.Equal(t, http.StatusPartialContent, rw.Code)
+				assert.Equal(t, "234", rw.Body.String())
+
+				rw = serve("/file", map[string]string{"Range": "bytes=2-4", "If-Range": `"stale"`})
+				assert.Equal(t, http.StatusOK, rw.Code)
+				assert.Equal(t, "0123456789", rw.Body.String())
+
+				rw = serve("/file", map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)})
+				assert.Equal(t, http.StatusNotModified, rw.Code)
+			},
+		},
+		{
+			Name: "file from fs",
+			Fn: func(t *testing.T) {
+				rw := serve("/fs/report.txt", nil)
+				assert.Equal(t, http.StatusOK, rw.Code)
+				assert.Equal(t, "0123456789", rw.Body.String())
+				rw = serve("/fs/missing.txt", nil)
+				assert.Equal(t, http.StatusNotFound, rw.Code)
+			},
+		},
+		{
+			Name: "attachment filename",
+			Fn: func(t *testing.T) {
+				rw := serve("/attachment", nil)
+				assert.Equal(t, http.StatusOK, rw.Code)
+				assert.Equal(t, `attachment; filename="______ 2021.txt"; filename*=UTF-8''%E5%A3%B2%E4%B8%8A%E3%83%AC%E3%83%9D%E3%83%BC%E3%83%88%202021.txt`,
+					rw.Header().Get(constant.HeaderContentDisposition))
+				assert.Equal(t, `attachment; filename="report.txt"`, fwncs.ContentDisposition("attachment", "report.txt"))
+			},
+		},
+		{
+			Name: "data from reader",
+			Fn: func(t *testing.T) {
+				rw := serve("/reader", map[string]string{"Range": "bytes=0-2"})
+				assert.Equal(t, http.StatusPartialContent, rw.Code)
+				assert.Equal(t, "abc", rw.Body.String())
+				rw = serve("/reader", map[string]string{"If-None-Match": `"reader"`})
+				assert.Equal(t, http.StatusNotModified, rw.Code)
+			},
+		},
+	}
+	tt.Run(t)
+}
+
+func TestCSV(t *testing.T) {
+	type audit struct {
+		CreatedAt time.Time `csv:"created_at" time_format:"2006-01-02"`
+	}
+	type user struct {
+		ID       int     `csv:"id"`
+		Name     string  `csv:"name"`
+		Score    float64 `csv:"score"`
+		Note     *string `csv:"note"`
+		Password string  `csv:"-"`
+		*audit
+	}
+	note := "a,\"b\""
+	created := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	users := []user{
+		{ID: 1, Name: "山田", Score: 1.5, Note: &note, Password: "secret", audit: &audit{CreatedAt: created}},
+		{ID: 2, Name: "鈴木"},
+	}
+	router := fwncs.New()
+	router.GET("/users.csv", func(c fwncs.Context) {
+		c.CSV(http.StatusOK, "ユーザー.csv", users)
+	})
+	router.GET("/users.tsv", func(c fwncs.Context) {
+		c.CSV(http.StatusOK, "users.tsv", [][]string{{"id", "name"}, {"1", "山田"}})
+	})
+	router.GET("/bom", func(c fwncs.Context) {
+		c.CSV(http.StatusOK, "", render.CSV{CSVOptions: render.CSVOptions{BOM: true, Header: []string{"ID", "氏名"}}, Rows: [][]string{{"1", "山田"}}})
+	})
+	router.GET("/sjis", func(c fwncs.Context) {
+		c.CSV(http.StatusOK, "users.csv", render.CSV{CSVOptions: render.CSVOptions{Encoding: japanese.ShiftJIS}, Rows: [][]string{{"山田", "😀"}}})
+	})
+	router.GET("/stream", func(c fwncs.Context) {
+		rows := make(chan *user)
+		go func() {
+			defer close(rows)
+			for i := range users {
+				rows <- &users[i]
+			}
+		}()
+		c.CSV(http.StatusOK, "users.csv", rows)
+	})
+	router.GET("/empty", func(c fwncs.Context) {
+		c.CSV(http.StatusOK, "users.csv", []user{})
+	})
+	router.GET("/invalid", func(c fwncs.Context) {
+		c.CSV(http.StatusOK, "users.csv", []int{1})
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+	expected := "id,name,score,note,created_at\n1,山田,1.5,\"a,\"\"b\"\"\",2021-07-01\n2,鈴木,0,,\n"
+	tt := tests.TestFrames{
+		{Name: "struct rows", Fn: func(t *testing.T) {
+			rw := serve("/users.csv")
+			assert.Equal(t, "text/csv; charset=utf-8", rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, fwncs.ContentDisposition("attachment", "ユーザー.csv"), rw.Header().Get(constant.HeaderContentDisposition))
+			assert.Equal(t, expected, rw.Body.String())
+		}},
+		{Name: "tsv", Fn: func(t *testing.T) {
+			rw := serve("/users.tsv")
+			assert.Equal(t, "text/tab-separated-values; charset=utf-8", rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, "id\tname\n1\t山田\n", rw.Body.String())
+		}},
+		{Name: "bom", Fn: func(t *testing.T) {
+			rw := serve("/bom")
+			assert.Empty(t, rw.Header().Get(constant.HeaderContentDisposition))
+			assert.Equal(t, "\xEF\xBB\xBFID,氏名\n1,山田\n", rw.Body.String())
+		}},
+		{Name: "shift_jis", Fn: func(t *testing.T) {
+			rw := serve("/sjis")
+			assert.Equal(t, "text/csv; charset=Shift_JIS", rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, "\x8eR\x93c,\x1a\n", rw.Body.String())
+		}},
+		{Name: "stream", Fn: func(t *testing.T) {
+			rw := serve("/stream")
+			assert.True(t, rw.Flushed)
+			assert.Equal(t, expected, rw.Body.String())
+		}},
+		{Name: "empty", Fn: func(t *testing.T) {
+			rw := serve("/empty")
+			assert.Equal(t, "id,name,score,note,created_at\n", rw.Body.String())
+		}},
+		{Name: "invalid", Fn: func(t *testing.T) {
+			rw := serve("/invalid")
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+			assert.Empty(t, rw.Header().Get(constant.HeaderContentDisposition))
+		}},
+	}
+	tt.Run(t)
+}
@@ -0,0 +1,176 @@
+package fwncs
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+// ForwardedElement is RFC 7239 の Forwarded ヘッダーの 1 要素 (1 つのプロキシ)
+// 	For, By は "192.0.2.60", "[2001:db8::1]:8080", "unknown", "_hidden" のようなノード
+type ForwardedElement struct {
+	For   string
+	By    string
+	Host  string
+	Proto string
+}
+
+// ParseForwarded is Forwarded ヘッダーを要素毎に分割する (複数行の場合は連結する)
+// 	不正な要素は無視する
+func ParseForwarded(header http.Header) []ForwardedElement {
+	elements := make([]ForwardedElement, 0)
+	for _, line := range header.Values(constant.HeaderForwarded) {
+		for _, value := range splitQuoted(line, ',') {
+			if element, ok := parseForwardedElement(value); ok {
+				elements = append(elements, element)
+			}
+		}
+	}
+	return elements
+}
+
+func parseForwardedElement(value string) (ForwardedElement, bool) {
+	var element ForwardedElement
+	pairs := splitQuoted(value, ';')
+	if len(pairs) == 0 {
+		return element, false
+	}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return element, false
+		}
+		v, ok := unquote(strings.TrimSpace(kv[1]))
+		if !ok {
+			return element, false
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "for":
+			element.For = v
+		case "by":
+			element.By = v
+		case "host":
+			element.Host = v
+		case "proto":
+			element.Proto = strings.ToLower(v)
+		}
+	}
+	return element, true
+}
+
+// String is Forwarded ヘッダーの値に変換する (token で表せない値は quoted-string にする)
+func (e ForwardedElement) String() string {
+	pairs := make([]string, 0, 4)
+	for _, kv := range [][2]string{{"for", e.For}, {"by", e.By}, {"host", e.Host}, {"proto", e.Proto}} {
+		if kv[1] != "" {
+			pairs = append(pairs, kv[0]+"="+quoteIfNeeded(kv[1]))
+		}
+	}
+	return strings.Join(pairs, ";")
+}
+
+// ForwardedNode is IP アドレス (ポートは含めない) を Forwarded の for, by の形式にする
+// 	IPv6 は [] で囲む
+func ForwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+// nodeIP is ノードの IP アドレス (IP でない場合は空文字)
+func nodeIP(node string) string {
+	host := node
+	if strings.HasPrefix(node, "[") {
+		end := strings.IndexByte(node, ']')
+		if end < 0 {
+			return ""
+		}
+		host = node[1:end]
+	} else if h, _, err := net.SplitHostPort(node); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// splitQuoted is quoted-string の中を除いて sep で分割する
+func splitQuoted(s string, sep byte) []string {
+	values := make([]string, 0)
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			if v := strings.TrimSpace(s[start:i]); v != "" {
+				values = append(values, v)
+			}
+			start = i + 1
+		}
+	}
+	if v := strings.TrimSpace(s[start:]); v != "" {
+		values = append(values, v)
+	}
+	return values
+}
+
+func unquote(s string) (string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, isToken(s)
+	}
+	if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+		return "", false
+	}
+	b := &strings.Builder{}
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), true
+}
+
+func quoteIfNeeded(s string) string {
+	if isToken(s) {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", ch) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// forwardedHop is Forwarded を右から辿り、信頼しないプロキシから受け取った要素を返す
+// 	for が unknown や _hidden のような IP アドレスでないノードは信頼しないプロキシとして扱う
+func (r *Router) forwardedHop(header http.Header) (ForwardedElement, bool) {
+	elements := ParseForwarded(header)
+	for i := len(elements) - 1; i >= 0; i-- {
+		if i == 0 || !r.isTrustedProxy(net.ParseIP(nodeIP(elements[i].For))) {
+			return elements[i], true
+		}
+	}
+	return ForwardedElement{}, false
+}
@@ -0,0 +1,57 @@
+package fwncs
+
+import "net/http"
+
+// HandlerFuncE is エラーを返す handler
+// 	返したエラーは Context.Error で記録して後続の処理を止め、何も書き込まれていなければ Router.ErrorHandler が返却する
+type HandlerFuncE func(c Context) error
+
+// E is HandlerFuncE を HandlerFunc に変換する
+func E(h HandlerFuncE) HandlerFunc {
+	return func(c Context) {
+		if err := h(c); err != nil {
+			c.Error(err)
+			c.Skip()
+		}
+	}
+}
+
+func (r *Router) HandlerE(method, path string, h ...HandlerFuncE) {
+	handlers := make(HandlerFuncChain, len(h))
+	for i, handler := range h {
+		handlers[i] = E(handler)
+	}
+	handlerName := ""
+	if len(h) > 0 {
+		handlerName = NameOfFunction(h[len(h)-1])
+	}
+	r.handle(method, path, handlerName, handlers)
+}
+
+func (r *Router) GETE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodGet, path, h...)
+}
+
+func (r *Router) POSTE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodPost, path, h...)
+}
+
+func (r *Router) PUTE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodPut, path, h...)
+}
+
+func (r *Router) DELETEE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodDelete, path, h...)
+}
+
+func (r *Router) PATCHE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodPatch, path, h...)
+}
+
+func (r *Router) HEADE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodHead, path, h...)
+}
+
+func (r *Router) OPTIONSE(path string, h ...HandlerFuncE) {
+	r.HandlerE(http.MethodOptions, path, h...)
+}
@@ -0,0 +1,287 @@
+package fwncs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSRFTokenKey is csrfField, csrfToken が参照する Context.Get のキー
+// 	CSRF 対策のミドルウェアはこのキーにトークンを設定する
+// 	テンプレートでは {{csrfField .}} のようにデータを渡し、データの csrf_token キーか CSRFToken() の値を使う
+// 	(Context.HTML はデータが nil か map[string]interface{} の場合にトークンを追加する)
+const CSRFTokenKey = "csrf_token"
+
+// HTMLConfig is Router.LoadHTMLGlob, Router.LoadHTMLFS の設定
+type HTMLConfig struct {
+	// Layout is ページを描画する時に実行するレイアウトのテンプレート名 (空の場合はページをそのまま実行する)
+	// 	レイアウトは {{block "content" .}}{{end}} などでページが define したテンプレートを呼び出す
+	Layout string
+	// Funcs is テンプレートで使う関数 (組み込みの url, csrfField, csrfToken と同じ名前の場合は上書きする)
+	Funcs template.FuncMap
+	// Reload is リクエスト毎にファイルの変更を確認して読み込み直す (開発環境向け)
+	Reload bool
+}
+
+// htmlEngine is 読み込み済みの HTML テンプレート
+// 	Layout と先頭が _ のファイル、layouts, partials ディレクトリのファイルは全てのページで共有する
+// 	それ以外のファイルはページとして共有するテンプレートの複製に個別に読み込む
+type htmlEngine struct {
+	mu       sync.RWMutex
+	router   *Router
+	fsys     fs.FS
+	patterns []string
+	config   HTMLConfig
+	shared   *template.Template
+	pages    map[string]*template.Template
+	modTimes map[string]time.Time
+}
+
+// LoadHTMLGlob is pattern に一致するファイルを HTML テンプレートとして読み込む
+// 	テンプレート名は pattern のワイルドカードを含まないディレクトリからの相対パスになる
+// 	(templates/*/*.html の場合は layouts/base.html, pages/index.html など)
+func (r *Router) LoadHTMLGlob(pattern string, config HTMLConfig) error {
+	pattern = filepath.ToSlash(pattern)
+	dir, rel := ".", pattern
+	if idx := strings.IndexAny(pattern, "*?[\\"); idx >= 0 {
+		if i := strings.LastIndex(pattern[:idx], "/"); i >= 0 {
+			dir, rel = pattern[:i], pattern[i+1:]
+			if dir == "" {
+				dir = "/"
+			}
+		}
+	} else {
+		dir, rel = path.Dir(pattern), path.Base(pattern)
+	}
+	return r.LoadHTMLFS(os.DirFS(filepath.FromSlash(dir)), config, rel)
+}
+
+// LoadHTMLFS is fsys の patterns に一致するファイルを HTML テンプレートとして読み込む
+// 	テンプレート名は fsys 内のパスになる (embed.FS のディレクトリを除く場合は fs.Sub を使う)
+func (r *Router) LoadHTMLFS(fsys fs.FS, config HTMLConfig, patterns ...string) error {
+	engine := &htmlEngine{
+		router:   r,
+		fsys:     fsys,
+		patterns: patterns,
+		config:   config,
+	}
+	if err := engine.load(); err != nil {
+		return err
+	}
+	r.html.mu.Lock()
+	defer r.html.mu.Unlock()
+	r.html.router = engine.router
+	r.html.fsys = engine.fsys
+	r.html.patterns = engine.patterns
+	r.html.config = engine.config
+	r.html.shared = engine.shared
+	r.html.pages = engine.pages
+	r.html.modTimes = engine.modTimes
+	return nil
+}
+
+// stat is patterns に一致するファイルと更新日時
+func (e *htmlEngine) stat() (map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	for _, pattern := range e.patterns {
+		matches, err := fs.Glob(e.fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			info, err := fs.Stat(e.fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				modTimes[name] = info.ModTime()
+			}
+		}
+	}
+	if len(modTimes) == 0 {
+		return nil, fmt.Errorf("fwncs: no HTML templates match %v", e.patterns)
+	}
+	return modTimes, nil
+}
+
+func (e *htmlEngine) load() error {
+	modTimes, err := e.stat()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(modTimes))
+	for name := range modTimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	shared := template.New("").Funcs(e.funcs())
+	pages := []string{}
+	for _, name := range names {
+		if !e.isShared(name) {
+			pages = append(pages, name)
+			continue
+		}
+		if err := parseHTML(shared, e.fsys, name); err != nil {
+			return err
+		}
+	}
+	if e.config.Layout != "" && shared.Lookup(e.config.Layout) == nil {
+		return fmt.Errorf("fwncs: HTML layout %q is not found", e.config.Layout)
+	}
+	e.pages = map[string]*template.Template{}
+	for _, name := range pages {
+		t, err := shared.Clone()
+		if err != nil {
+			return err
+		}
+		if err := parseHTML(t, e.fsys, name); err != nil {
+			return err
+		}
+		e.pages[name] = t
+	}
+	e.shared = shared
+	e.modTimes = modTimes
+	return nil
+}
+
+func parseHTML(t *template.Template, fsys fs.FS, name string) error {
+	buf, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(name).Parse(string(buf))
+	return err
+}
+
+func (e *htmlEngine) isShared(name string) bool {
+	if name == e.config.Layout || strings.HasPrefix(path.Base(name), "_") {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if dir == "layouts" || dir == "partials" {
+			return true
+		}
+	}
+	return false
+}
+
+// funcs is 組み込みの関数と config.Funcs
+// 	読み込んだテンプレートはリクエスト間で共有するので、csrfField, csrfToken は引数のデータからトークンを取得する
+func (e *htmlEngine) funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"url":       e.router.Reverse,
+		"csrfToken": csrfToken,
+		"csrfField": func(data ...interface{}) template.HTML {
+			return template.HTML(`<input type="hidden" name="` + CSRFTokenKey + `" value="` + template.HTMLEscapeString(csrfToken(data...)) + `">`)
+		},
+	}
+	for name, fn := range e.config.Funcs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// csrfToken is data の csrf_token キーか CSRFToken() の値 (無い場合は空文字)
+func csrfToken(data ...interface{}) string {
+	if len(data) == 0 {
+		return ""
+	}
+	switch v := data[0].(type) {
+	case interface{ CSRFToken() string }:
+		return v.CSRFToken()
+	case map[string]interface{}:
+		token, _ := v[CSRFTokenKey].(string)
+		return token
+	case map[string]string:
+		return v[CSRFTokenKey]
+	}
+	return ""
+}
+
+// withCSRFToken is data が nil か map[string]interface{} の場合に token を追加したデータを返す
+// 	呼び出し元の map は変更しない
+func withCSRFToken(data interface{}, token string) interface{} {
+	switch v := data.(type) {
+	case nil:
+		return map[string]interface{}{CSRFTokenKey: token}
+	case map[string]interface{}:
+		if _, ok := v[CSRFTokenKey]; ok {
+			return data
+		}
+		copied := make(map[string]interface{}, len(v)+1)
+		for key, value := range v {
+			copied[key] = value
+		}
+		copied[CSRFTokenKey] = token
+		return copied
+	}
+	return data
+}
+
+// reload is ファイルが追加、削除、更新されている場合に読み込み直す
+func (e *htmlEngine) reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	modTimes, err := e.stat()
+	if err != nil {
+		return err
+	}
+	changed := len(modTimes) != len(e.modTimes)
+	for name, modTime := range modTimes {
+		if old, ok := e.modTimes[name]; !ok || !old.Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return e.load()
+}
+
+// execute is name のテンプレートを実行する
+// 	name がページで Layout が設定されている場合はページを読み込んだ Layout を実行する
+// 	c にトークンが設定されている場合は csrfField などのためにデータに追加する
+func (e *htmlEngine) execute(c Context, name string, data interface{}) ([]byte, error) {
+	e.mu.RLock()
+	reload := e.config.Reload && e.fsys != nil
+	e.mu.RUnlock()
+	if reload {
+		if err := e.reload(); err != nil {
+			return nil, err
+		}
+	}
+	e.mu.RLock()
+	if e.shared == nil {
+		e.mu.RUnlock()
+		return nil, errors.New("fwncs: HTML templates are not loaded")
+	}
+	t, exec := e.shared, name
+	if page, ok := e.pages[name]; ok {
+		t = page
+		if e.config.Layout != "" {
+			exec = e.config.Layout
+		}
+	}
+	e.mu.RUnlock()
+	if t.Lookup(exec) == nil {
+		return nil, fmt.Errorf("fwncs: HTML template %q is not found", name)
+	}
+	if token, _ := c.Get(CSRFTokenKey).(string); token != "" {
+		data = withCSRFToken(data, token)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, exec, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,214 @@
+package fwncs_test
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	fwncs "github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func showUser(c fwncs.Context) {
+	c.String(http.StatusOK, c.Param("id"))
+}
+
+type csrfPage struct {
+	Title string
+	token string
+}
+
+func (p csrfPage) CSRFToken() string {
+	return p.token
+}
+
+func TestHTMLTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/layouts/base.html":  {Data: []byte(`<title>{{block "title" .}}default{{end}}</title><main>{{block "content" .}}{{end}}</main>`)},
+		"views/partials/user.html": {Data: []byte(`{{define "user"}}<a href="{{url "showUser" .ID}}">{{.Name}}</a>{{end}}`)},
+		"views/index.html":         {Data: []byte(`{{define "title"}}{{upper .Title}}{{end}}{{define "content"}}{{template "user" .User}}{{end}}`)},
+		"views/form.html":          {Data: []byte(`{{define "content"}}<form action="{{url "/files/*path" "a b/c.txt"}}">{{csrfField .}}</form>{{end}}`)},
+		"views/token.html":         {Data: []byte(`{{define "content"}}{{.Title}}:{{csrfToken .}}{{end}}`)},
+		"views/broken.html":        {Data: []byte(`{{define "content"}}{{.Missing.Field}}{{end}}`)},
+	}
+	router := fwncs.New()
+	router.GET("/users/:id", showUser)
+	router.GET("/files/*path", func(c fwncs.Context) {})
+	err := router.LoadHTMLFS(fsys, fwncs.HTMLConfig{
+		Layout: "views/layouts/base.html",
+		Funcs:  template.FuncMap{"upper": strings.ToUpper},
+	}, "views/*.html", "views/*/*.html")
+	assert.NoError(t, err)
+	router.GET("/", func(c fwncs.Context) {
+		c.HTML(http.StatusOK, "views/index.html", map[string]interface{}{
+			"Title": "users",
+			"User":  map[string]interface{}{"ID": 10, "Name": "<taro>"},
+		})
+	})
+	router.GET("/form", func(c fwncs.Context) {
+		c.Set(fwncs.CSRFTokenKey, `to"ken`)
+		c.HTML(http.StatusOK, "views/form.html", nil)
+	})
+	router.GET("/token", func(c fwncs.Context) {
+		c.Set(fwncs.CSRFTokenKey, c.QueryParam("token"))
+		switch c.QueryParam("data") {
+		case "struct":
+			c.HTML(http.StatusOK, "views/token.html", csrfPage{Title: "struct", token: "from-struct"})
+		default:
+			data := map[string]interface{}{"Title": "map"}
+			c.HTML(http.StatusOK, "views/token.html", data)
+			assert.NotContains(t, data, fwncs.CSRFTokenKey)
+		}
+	})
+	router.GET("/partial", func(c fwncs.Context) {
+		c.HTML(http.StatusOK, "user", map[string]interface{}{"ID": "a/b", "Name": "hanako"})
+	})
+	router.GET("/broken", func(c fwncs.Context) {
+		c.HTML(http.StatusOK, "views/broken.html", 1)
+	})
+	router.GET("/missing", func(c fwncs.Context) {
+		c.HTML(http.StatusOK, "views/missing.html", nil)
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+	tt := tests.TestFrames{
+		{Name: "layout and partial", Fn: func(t *testing.T) {
+			rw := serve("/")
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, constant.HTML.String(), rw.Header().Get(constant.HeaderContentType))
+			assert.Equal(t, `<title>USERS</title><main><a href="/users/10">&lt;taro&gt;</a></main>`, rw.Body.String())
+		}},
+		{Name: "csrf field", Fn: func(t *testing.T) {
+			rw := serve("/form")
+			assert.Equal(t, `<title>default</title><main><form action="/files/a%20b/c.txt"><input type="hidden" name="csrf_token" value="to&#34;ken"></form></main>`, rw.Body.String())
+		}},
+		{Name: "csrf token from data", Fn: func(t *testing.T) {
+			rw := serve("/token?token=abc")
+			assert.Equal(t, `<title>default</title><main>map:abc</main>`, rw.Body.String())
+			rw = serve("/token?token=abc&data=struct")
+			assert.Equal(t, `<title>default</title><main>struct:from-struct</main>`, rw.Body.String())
+		}},
+		{Name: "concurrent csrf tokens", Fn: func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					token := fmt.Sprintf("token%d", i)
+					rw := serve("/token?token=" + token)
+					assert.Equal(t, `<title>default</title><main>map:`+token+`</main>`, rw.Body.String())
+				}(i)
+			}
+			wg.Wait()
+		}},
+		{Name: "partial without layout", Fn: func(t *testing.T) {
+			rw := serve("/partial")
+			assert.Equal(t, `<a href="/users/a%2Fb">hanako</a>`, rw.Body.String())
+		}},
+		{Name: "execute error", Fn: func(t *testing.T) {
+			rw := serve("/broken")
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+			assert.NotContains(t, rw.Body.String(), "<main>")
+		}},
+		{Name: "not found", Fn: func(t *testing.T) {
+			rw := serve("/missing")
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+		}},
+		{Name: "unknown layout", Fn: func(t *testing.T) {
+			err := fwncs.New().LoadHTMLFS(fsys, fwncs.HTMLConfig{Layout: "base.html"}, "views/*.html")
+			assert.Error(t, err)
+		}},
+		{Name: "reverse", Fn: func(t *testing.T) {
+			u, err := router.Reverse("showUser", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "/users/1", u)
+			_, err = router.Reverse("showUser")
+			assert.Error(t, err)
+			_, err = router.Reverse("unknown")
+			assert.Error(t, err)
+		}},
+	}
+	tt.Run(t)
+}
+
+func TestContextTemplate(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	assert.NoError(t, os.WriteFile(page, []byte(`<p>{{.}}</p>`), 0o600))
+	router := fwncs.New()
+	router.GET("/file", func(c fwncs.Context) {
+		c.Template(http.StatusOK, "<taro>", page)
+	})
+	router.GET("/text", func(c fwncs.Context) {
+		c.TemplateText(http.StatusOK, `<p>{{.}}</p>`, "<taro>")
+	})
+	router.GET("/missing", func(c fwncs.Context) {
+		c.Template(http.StatusOK, nil, filepath.Join(dir, "missing.html"))
+	})
+	router.GET("/syntax", func(c fwncs.Context) {
+		c.TemplateText(http.StatusOK, `<p>{{.</p>`, nil)
+	})
+	router.GET("/execute", func(c fwncs.Context) {
+		c.TemplateText(http.StatusOK, `<p>{{.Missing.Field}}</p>`, struct{}{})
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		assert.NotPanics(t, func() { router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil)) }, path)
+		return rw
+	}
+	for _, path := range []string{"/file", "/text"} {
+		rw := serve(path)
+		assert.Equal(t, http.StatusOK, rw.Code, path)
+		assert.Equal(t, constant.HTML.String(), rw.Header().Get(constant.HeaderContentType), path)
+		assert.Equal(t, "<p>&lt;taro&gt;</p>", rw.Body.String(), path)
+	}
+	for _, path := range []string{"/missing", "/syntax", "/execute"} {
+		rw := serve(path)
+		assert.Equal(t, http.StatusInternalServerError, rw.Code, path)
+		assert.NotContains(t, rw.Body.String(), "<p>", path)
+	}
+}
+
+func TestHTMLTemplateReload(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	write := func(body string, modTime time.Time) {
+		assert.NoError(t, os.WriteFile(page, []byte(body), 0o600))
+		assert.NoError(t, os.Chtimes(page, modTime, modTime))
+	}
+	now := time.Now()
+	write(`v1 {{.}}`, now.Add(-time.Hour))
+	serve := func(router *fwncs.Router) string {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rw.Body.String()
+	}
+	newRouter := func(reload bool) *fwncs.Router {
+		router := fwncs.New()
+		assert.NoError(t, router.Group("/").LoadHTMLGlob(filepath.Join(dir, "*.html"), fwncs.HTMLConfig{Reload: reload}))
+		router.GET("/", func(c fwncs.Context) {
+			c.HTML(http.StatusOK, "index.html", "page")
+		})
+		return router
+	}
+	cached, reloaded := newRouter(false), newRouter(true)
+	assert.Equal(t, "v1 page", serve(cached))
+	assert.Equal(t, "v1 page", serve(reloaded))
+
+	write(`v2 {{.}}`, now)
+	assert.Equal(t, "v1 page", serve(cached))
+	assert.Equal(t, "v2 page", serve(reloaded))
+}
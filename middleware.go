@@ -59,6 +59,15 @@ func Recovery() HandlerFunc {
 					}
 					c.Logger().Error(fmt.Sprintf("%d: %v:%d", depth, file, line))
 				}
+				if useProblemDetails(c) {
+					c.AbortWithProblem(NewProblem(http.StatusInternalServerError, ""))
+					return
+				}
+				if prefersHTMLErrorPage(c) && !c.IsSkip() {
+					c.Skip()
+					renderErrorPage(c, http.StatusInternalServerError, constant.JSONAscii.String())
+					return
+				}
 				c.AbortWithStatusAndMessage(http.StatusInternalServerError, NewDefaultResponseBody(http.StatusInternalServerError, fmt.Sprintf("%v", rcv)))
 			}
 		}()
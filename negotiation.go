@@ -0,0 +1,232 @@
+package fwncs
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/render"
+	"google.golang.org/protobuf/proto"
+)
+
+// Negotiation is Context.Negotiate で Accept ヘッダーに応じて返却する内容
+// 	Data はフォーマット毎の値が無い場合に使われる
+// 	Offered が空の場合は値が設定されているフォーマットを JSON, XML, YAML, MessagePack, Protocol Buffers, HTML の順に提示する
+// 	Protocol Buffers は ProtoBuf か Data が proto.Message の場合だけ、XML は値が map などエンコードできない型でない場合だけ提示する
+// 	Offered を指定した場合も、値が nil のフォーマットやエンコードできないフォーマットは提示しない
+type Negotiation struct {
+	Offered  []string
+	JSON     interface{}
+	XML      interface{}
+	YAML     interface{}
+	MsgPack  interface{}
+	ProtoBuf proto.Message
+	HTML     render.Render
+	Data     interface{}
+}
+
+func (n Negotiation) offers() []string {
+	candidates := n.Offered
+	if len(candidates) == 0 {
+		candidates = defaultOffers
+	}
+	offers := make([]string, 0, len(candidates))
+	for _, offer := range candidates {
+		if n.canRender(offer) {
+			offers = append(offers, offer)
+		}
+	}
+	return offers
+}
+
+var defaultOffers = []string{
+	constant.JSON.String(),
+	constant.XML.String(),
+	constant.YAML.String(),
+	constant.MSGPACK.String(),
+	constant.MSGPACK2.String(),
+	constant.ProtocolBuffer.String(),
+	constant.HTML.String(),
+}
+
+// canRender is offer のフォーマットで返却する値があり、エンコードできるか
+func (n Negotiation) canRender(offer string) bool {
+	switch mediaType(offer) {
+	case mediaType(constant.JSON.String()):
+		return !isNilValue(n.data(n.JSON))
+	case mediaType(constant.XML.String()), mediaType(constant.XML2.String()):
+		v := n.data(n.XML)
+		return !isNilValue(v) && canEncodeXML(v)
+	case mediaType(constant.YAML.String()):
+		return !isNilValue(n.data(n.YAML))
+	case mediaType(constant.MSGPACK.String()), mediaType(constant.MSGPACK2.String()):
+		return !isNilValue(n.data(n.MsgPack))
+	case mediaType(constant.ProtocolBuffer.String()):
+		_, ok := n.Data.(proto.Message)
+		return ok || n.ProtoBuf != nil
+	case mediaType(constant.HTML.String()):
+		return n.HTML != nil
+	}
+	return true
+}
+
+// isNilValue is v が nil または nil のポインタ、map、slice か (JSON などでは null になる)
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// canEncodeXML is encoding/xml がエンコードできない map, chan, func などの型でないか
+func canEncodeXML(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		// []byte は文字列としてエンコードされる
+		if t.Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+		t = t.Elem()
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
+
+func (n Negotiation) data(v interface{}) interface{} {
+	if v != nil {
+		return v
+	}
+	return n.Data
+}
+
+func (c *_context) Negotiate(status int, config Negotiation) {
+	format := c.NegotiateFormat(config.offers()...)
+	switch mediaType(format) {
+	case mediaType(constant.JSON.String()):
+		c.JSON(status, config.data(config.JSON))
+	case mediaType(constant.XML.String()), mediaType(constant.XML2.String()):
+		c.Render(status, render.XML{Data: config.data(config.XML)})
+	case mediaType(constant.YAML.String()):
+		c.YAML(status, config.data(config.YAML))
+	case mediaType(constant.MSGPACK.String()), mediaType(constant.MSGPACK2.String()):
+		c.MsgPack(status, config.data(config.MsgPack))
+	case mediaType(constant.ProtocolBuffer.String()):
+		if config.ProtoBuf != nil {
+			c.ProtoBuf(status, config.ProtoBuf)
+			return
+		}
+		c.ProtoBuf(status, config.Data)
+	case mediaType(constant.HTML.String()):
+		c.Render(status, config.HTML)
+	default:
+		c.AbortWithStatus(http.StatusNotAcceptable)
+	}
+}
+
+func (c *_context) NegotiateFormat(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accepts := parseAccept(c.Header().Get(constant.HeaderAccept))
+	if len(accepts) == 0 {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := accepts.quality(mediaType(offer)); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity is type/subtype > type/* > */* の順に大きくなる
+func (a acceptRange) specificity() int {
+	switch {
+	case a.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func (a acceptRange) match(mediaType string) bool {
+	switch a.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(a.mediaType, "*"))
+	}
+	return a.mediaType == mediaType
+}
+
+type acceptRanges []acceptRange
+
+// quality is mediaType に最も具体的に一致する範囲の q 値を返す
+func (ranges acceptRanges) quality(mediaType string) float64 {
+	for _, r := range ranges {
+		if r.match(mediaType) {
+			return r.q
+		}
+	}
+	return 0
+}
+
+// parseAccept is Accept ヘッダーを具体的な順に並べた範囲の一覧にする
+func parseAccept(header string) acceptRanges {
+	ranges := make(acceptRanges, 0)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		if mt == "" {
+			continue
+		}
+		if mt == "*" {
+			mt = "*/*"
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "q") {
+				if f, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mt, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+func mediaType(contentType string) string {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
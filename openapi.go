@@ -0,0 +1,230 @@
+package fwncs
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/openapi"
+	"github.com/n-creativesystem/go-fwncs/render"
+)
+
+const (
+	defaultOpenAPIPath     = "/openapi.json"
+	defaultSwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@5"
+	openIDSecurityScheme   = "openId"
+)
+
+var swaggerUITemplate = template.Must(template.New("swagger-ui").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: {{.URL}}, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`))
+
+// OpenAPIConfig is Router.OpenAPI の設定
+type OpenAPIConfig struct {
+	// Path is ドキュメントを返すパス (空の場合は /openapi.json)
+	Path string
+	// SwaggerUIPath is Swagger UI を返すパス (空の場合は登録しない)
+	SwaggerUIPath string
+	// SwaggerUIAssets is swagger-ui-dist の URL (空の場合は unpkg)
+	SwaggerUIAssets string
+	Info            openapi.Info
+	Servers         []openapi.Server
+}
+
+// OpenAPI is 登録されたルートから作成した OpenAPI 3.0 のドキュメントを config.Path で返す
+// 	ドキュメントはリクエスト毎に作成するため、後から登録したルートも含まれる
+// 	ドキュメントと Swagger UI のルート自体はドキュメントに含めない
+func (r *Router) OpenAPI(config OpenAPIConfig) {
+	if config.Path == "" {
+		config.Path = defaultOpenAPIPath
+	}
+	spec := func(c Context) {
+		c.JSON(http.StatusOK, r.OpenAPIDocument(config))
+	}
+	r.With(HiddenOption()).GET(config.Path, spec)
+	if config.SwaggerUIPath == "" {
+		return
+	}
+	assets := config.SwaggerUIAssets
+	if assets == "" {
+		assets = defaultSwaggerUIAssets
+	}
+	title := config.Info.Title
+	if title == "" {
+		title = "Swagger UI"
+	}
+	data := map[string]string{
+		"Title":  title,
+		"Assets": strings.TrimSuffix(assets, "/"),
+		"URL":    r.path(config.Path),
+	}
+	ui := func(c Context) {
+		c.Render(http.StatusOK, render.TemplateRender{Template: swaggerUITemplate, Data: data})
+	}
+	r.With(HiddenOption()).GET(config.SwaggerUIPath, ui)
+}
+
+// OpenAPIDocument is 登録されたルートから OpenAPI 3.0 のドキュメントを作成する
+// 	Typed, HandleTyped のルートは Req の path, query, header タグをパラメータ、それ以外を request body、Resp を 200 のレスポンスにする
+// 	Secure, SecurityOption の issuer は openIdConnect の securityScheme、scopes はその scope になる
+func (r *Router) OpenAPIDocument(config OpenAPIConfig) *openapi.Document {
+	info := config.Info
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "1.0.0"
+	}
+	doc := openapi.New(info)
+	doc.Servers = config.Servers
+	generator := openapi.NewGenerator(doc.Components)
+	schemes := map[string]string{}
+	operationIDs := map[string]int{}
+	operations := map[*openapi.Operation]string{}
+	for _, route := range r.Routes() {
+		if route.Hidden {
+			continue
+		}
+		path, pathParams := openAPIPath(route.Path)
+		op := &openapi.Operation{
+			Responses: map[string]*openapi.Response{},
+		}
+		if route.Request != nil {
+			op.Parameters = generator.Parameters(route.Request)
+			if hasRequestBody(route.Method) {
+				if body := generator.Body(route.Request); body != nil {
+					op.RequestBody = &openapi.RequestBody{
+						Required: true,
+						Content:  map[string]*openapi.MediaType{"application/json": {Schema: body}},
+					}
+				}
+			}
+			if len(op.Parameters) > 0 || op.RequestBody != nil {
+				op.Responses["400"] = &openapi.Response{Description: http.StatusText(http.StatusBadRequest)}
+			}
+		}
+		op.Parameters = appendPathParameters(op.Parameters, pathParams)
+		success := &openapi.Response{Description: http.StatusText(http.StatusOK)}
+		if route.Response != nil {
+			success.Content = map[string]*openapi.MediaType{"application/json": {Schema: generator.Schema(route.Response)}}
+		}
+		op.Responses["200"] = success
+		op.Security = openAPISecurity(doc, schemes, route.Security)
+		if len(op.Security) > 0 {
+			op.Responses["401"] = &openapi.Response{Description: http.StatusText(http.StatusUnauthorized)}
+		}
+		for _, security := range route.Security {
+			if len(security.Scopes) > 0 {
+				op.Responses["403"] = &openapi.Response{Description: http.StatusText(http.StatusForbidden)}
+			}
+		}
+		if id := operationID(route.HandlerName); id != "" {
+			operationIDs[id]++
+			operations[op] = id
+		}
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+	// operationId は一意でなければならないので重複したものは出力しない
+	for op, id := range operations {
+		if operationIDs[id] == 1 {
+			op.OperationID = id
+		}
+	}
+	return doc
+}
+
+// openAPIPath is /users/:id を /users/{id} に変換し、パラメータ名を返す
+func openAPIPath(routePath string) (string, []string) {
+	routePath = strings.TrimPrefix(routePath, "= ")
+	routePath = strings.TrimPrefix(routePath, "~ ")
+	params := []string{}
+	for _, match := range routeParam.FindAllStringSubmatch(routePath, -1) {
+		params = append(params, match[1])
+	}
+	return routeParam.ReplaceAllString(routePath, "{$1}"), params
+}
+
+// appendPathParameters is Req に無いパスパラメータを文字列として追加する
+func appendPathParameters(params []*openapi.Parameter, names []string) []*openapi.Parameter {
+	for _, name := range names {
+		found := false
+		for _, p := range params {
+			if p.In == "path" && p.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			params = append(params, &openapi.Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "string"},
+			})
+		}
+	}
+	return params
+}
+
+func hasRequestBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// openAPISecurity is route の issuer を securityScheme に登録し、scope を付けた security を返す
+// 	schemes は issuer と securityScheme 名の対応
+func openAPISecurity(doc *openapi.Document, schemes map[string]string, route []RouteSecurity) []openapi.SecurityRequirement {
+	security := make([]openapi.SecurityRequirement, 0, len(route))
+	for _, rs := range route {
+		name, ok := schemes[rs.Issuer]
+		if !ok {
+			name = openIDSecurityScheme
+			if len(schemes) > 0 {
+				name = fmt.Sprintf("%s%d", openIDSecurityScheme, len(schemes)+1)
+			}
+			schemes[rs.Issuer] = name
+			doc.Components.SecuritySchemes[name] = &openapi.SecurityScheme{
+				Type:             "openIdConnect",
+				OpenIDConnectURL: strings.TrimSuffix(rs.Issuer, "/") + "/.well-known/openid-configuration",
+			}
+		}
+		security = append(security, openapi.SecurityRequirement{name: append([]string{}, rs.Scopes...)})
+	}
+	return security
+}
+
+// operationID is handler の関数名 (無名関数の場合は空)
+func operationID(handlerName string) string {
+	name := handlerName[strings.LastIndex(handlerName, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if strings.Contains(name, ".func") {
+		return ""
+	}
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load is JSON か YAML の OpenAPI 3 のドキュメントを読み込む
+func Load(filename string) (*Document, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse is JSON か YAML の OpenAPI 3 のドキュメントを解析する
+// 	YAML は JSON に変換してから解析する
+func Parse(data []byte) (*Document, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		buf, err := json.Marshal(yamlToJSON(v))
+		if err != nil {
+			return nil, err
+		}
+		data = buf
+	}
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version %q", doc.OpenAPI)
+	}
+	if doc.Components == nil {
+		doc.Components = &Components{}
+	}
+	return doc, nil
+}
+
+// yamlToJSON is responses の 200 などの文字列以外のキーを文字列にする
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = yamlToJSON(value)
+		}
+		return m
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = yamlToJSON(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = yamlToJSON(value)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,367 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// parameterTags is binding.MapValues で読み込むタグと Parameter の in の対応
+var parameterTags = []struct {
+	tag string
+	in  string
+}{
+	{tag: "path", in: "path"},
+	{tag: "query", in: "query"},
+	{tag: "header", in: "header"},
+}
+
+// Generator is Go の型から Schema を作成する
+// 	名前付きの構造体は Components.Schemas に登録して $ref で参照する
+type Generator struct {
+	components *Components
+	types      map[reflect.Type]string
+	names      map[string]reflect.Type
+}
+
+func NewGenerator(components *Components) *Generator {
+	if components.Schemas == nil {
+		components.Schemas = map[string]*Schema{}
+	}
+	return &Generator{
+		components: components,
+		types:      map[reflect.Type]string{},
+		names:      map[string]reflect.Type{},
+	}
+}
+
+// Schema is t を JSON で表現した Schema
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, false)
+		}
+		return g.ref(t)
+	}
+	return &Schema{}
+}
+
+// Parameters is path, query, header タグのフィールドを Parameter にする
+// 	path は常に必須、それ以外は binding:"required" の場合に必須になる
+func (g *Generator) Parameters(t reflect.Type) []*Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	params := []*Parameter{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		in, name, opts, ok := parameterTag(field)
+		if !ok {
+			// binding.MapValues と同様にタグが無い構造体は中のフィールドを探す
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !isScalar(ft) {
+				params = append(params, g.Parameters(ft)...)
+			}
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+		schema := g.Schema(field.Type)
+		if layout := field.Tag.Get("time_format"); layout != "" && schema.Format == "date-time" {
+			schema.Format = ""
+			if layout == "2006-01-02" {
+				schema.Format = "date"
+			}
+		}
+		required := applyBinding(schema, field.Tag.Get("binding"))
+		if def, ok := opts["default"]; ok {
+			schema.Default = defaultValue(schema, def)
+		}
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       in,
+			Required: in == "path" || required,
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// Body is path, query, header タグが無いフィールドから request body の Schema を作成する
+// 	該当するフィールドが無い場合は nil を返す
+func (g *Generator) Body(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return g.Schema(t)
+	}
+	if len(g.Parameters(t)) == 0 {
+		if len(g.object(t, true).Properties) == 0 {
+			return nil
+		}
+		return g.Schema(t)
+	}
+	body := g.object(t, true)
+	if len(body.Properties) == 0 {
+		return nil
+	}
+	return body
+}
+
+func (g *Generator) ref(t reflect.Type) *Schema {
+	name, ok := g.types[t]
+	if !ok {
+		name = t.Name()
+		if other, ok := g.names[name]; ok && other != t {
+			name = path.Base(t.PkgPath()) + "." + t.Name()
+		}
+		g.types[t] = name
+		g.names[name] = t
+		// 再帰する型のために先に登録する
+		schema := &Schema{}
+		g.components.Schemas[name] = schema
+		*schema = *g.object(t, false)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object is encoding/json と同じ規則で構造体のプロパティを作成する
+// 	skipParameters の場合は path, query, header タグのフィールドを除く
+func (g *Generator) object(t reflect.Type, skipParameters bool) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if skipParameters {
+			if _, _, _, ok := parameterTag(field); ok {
+				continue
+			}
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" {
+			if ft.Kind() == reflect.Struct {
+				embedded := g.object(ft, skipParameters)
+				for key, value := range embedded.Properties {
+					schema.Properties[key] = value
+				}
+				schema.Required = append(schema.Required, embedded.Required...)
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := g.Schema(field.Type)
+		if applyBinding(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+func parameterTag(field reflect.StructField) (in, name string, opts map[string]string, ok bool) {
+	for _, p := range parameterTags {
+		value, found := field.Tag.Lookup(p.tag)
+		if !found {
+			continue
+		}
+		parts := strings.Split(value, ",")
+		opts = map[string]string{}
+		for _, opt := range parts[1:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) == 2 {
+				opts[strings.TrimSpace(kv[0])] = kv[1]
+			}
+		}
+		name = strings.TrimSpace(parts[0])
+		if name == "" {
+			name = field.Name
+		}
+		return p.in, name, opts, true
+	}
+	return "", "", nil, false
+}
+
+func isScalar(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// applyBinding is binding タグの検証ルールを Schema の制約にして、required かを返す
+// 	dive 以降は要素に対するルールなので無視する
+func applyBinding(schema *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" || rule == "keys" {
+			break
+		}
+		if strings.Contains(rule, "|") {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		key, param := kv[0], ""
+		if len(kv) == 2 {
+			param = kv[1]
+		}
+		if key == "required" {
+			required = true
+			continue
+		}
+		if schema.Ref != "" {
+			continue
+		}
+		switch key {
+		case "min", "gte":
+			setLimit(schema, param, true, false)
+		case "max", "lte":
+			setLimit(schema, param, false, false)
+		case "gt":
+			setLimit(schema, param, true, true)
+		case "lt":
+			setLimit(schema, param, false, true)
+		case "len":
+			setLimit(schema, param, true, false)
+			setLimit(schema, param, false, false)
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, defaultValue(schema, value))
+			}
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid", "uuid3", "uuid4", "uuid5":
+			schema.Format = "uuid"
+		case "ipv4", "ipv6":
+			schema.Format = key
+		}
+	}
+	return required
+}
+
+// setLimit is 型に応じて minimum, minLength, minItems などを設定する
+func setLimit(schema *Schema, param string, min, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if min {
+			schema.Minimum = &n
+			schema.ExclusiveMinimum = exclusive
+		} else {
+			schema.Maximum = &n
+			schema.ExclusiveMaximum = exclusive
+		}
+	case "string", "array":
+		if exclusive {
+			if min {
+				n++
+			} else {
+				n--
+			}
+		}
+		if n < 0 {
+			return
+		}
+		length := uint64(n)
+		switch {
+		case schema.Type == "string" && min:
+			schema.MinLength = &length
+		case schema.Type == "string":
+			schema.MaxLength = &length
+		case min:
+			schema.MinItems = &length
+		default:
+			schema.MaxItems = &length
+		}
+	}
+}
+
+// defaultValue is 文字列を Schema の型の値に変換する (変換できない場合は文字列のまま)
+func defaultValue(schema *Schema, value string) interface{} {
+	switch schema.Type {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
@@ -0,0 +1,360 @@
+package openapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	patterns    sync.Map
+)
+
+// ValidationError is 値が Schema に違反した箇所
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Schema is $ref を解決した Schema
+func (d *Document) Schema(s *Schema) *Schema {
+	for i := 0; s != nil && s.Ref != "" && i < 32; i++ {
+		s = d.Components.Schemas[refName(s.Ref, "schemas")]
+	}
+	return s
+}
+
+// Parameter is $ref を解決した Parameter
+func (d *Document) Parameter(p *Parameter) *Parameter {
+	for i := 0; p != nil && p.Ref != "" && i < 32; i++ {
+		p = d.Components.Parameters[refName(p.Ref, "parameters")]
+	}
+	return p
+}
+
+// RequestBody is $ref を解決した RequestBody
+func (d *Document) RequestBody(b *RequestBody) *RequestBody {
+	for i := 0; b != nil && b.Ref != "" && i < 32; i++ {
+		b = d.Components.RequestBodies[refName(b.Ref, "requestBodies")]
+	}
+	return b
+}
+
+// Response is $ref を解決した Response
+func (d *Document) Response(r *Response) *Response {
+	for i := 0; r != nil && r.Ref != "" && i < 32; i++ {
+		r = d.Components.Responses[refName(r.Ref, "responses")]
+	}
+	return r
+}
+
+// refName is #/components/{kind}/{name} の name を返す (JSON Pointer の ~1, ~0 を戻す)
+func refName(ref, kind string) string {
+	name := strings.TrimPrefix(ref, "#/components/"+kind+"/")
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+}
+
+// ValidateParameter is 文字列の values を schema の型に変換して検証する
+// 	配列は values をそのまま使い、explode でない場合はカンマで分割する
+func (d *Document) ValidateParameter(p *Parameter, values []string) ValidationErrors {
+	p = d.Parameter(p)
+	if p == nil {
+		return nil
+	}
+	field := p.In + "." + p.Name
+	if len(values) == 0 {
+		if p.Required || p.In == "path" {
+			return ValidationErrors{{Field: field, Message: "is required"}}
+		}
+		return nil
+	}
+	schema := d.Schema(p.Schema)
+	if schema == nil {
+		return nil
+	}
+	var value interface{}
+	if schema.Type == "array" {
+		// explode のデフォルトは query, cookie が true、path, header が false
+		explode := p.In == "query" || p.In == "cookie"
+		if p.Explode != nil {
+			explode = *p.Explode
+		}
+		if len(values) == 1 && !explode {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = parseParameter(d.Schema(schema.Items), v)
+		}
+		value = items
+	} else {
+		value = parseParameter(schema, values[0])
+	}
+	return d.ValidateValue(schema, value, field)
+}
+
+// parseParameter is schema の型に変換できる場合は変換し、変換できない場合は文字列のまま返す
+func parseParameter(schema *Schema, value string) interface{} {
+	if schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// ValidateValue is encoding/json で decode した value を schema で検証する
+// 	数値は float64 か json.Number で、field はエラーの位置に使う
+func (d *Document) ValidateValue(schema *Schema, value interface{}, field string) ValidationErrors {
+	schema = d.Schema(schema)
+	if schema == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, s := range schema.AllOf {
+		errs = append(errs, d.ValidateValue(s, value, field)...)
+	}
+	if len(schema.AnyOf) > 0 && d.matches(schema.AnyOf, value, field) == 0 {
+		add("must match at least one schema")
+	}
+	if len(schema.OneOf) > 0 && d.matches(schema.OneOf, value, field) != 1 {
+		add("must match exactly one schema")
+	}
+	if schema.Not != nil && len(d.ValidateValue(schema.Not, value, field)) == 0 {
+		add("is not allowed")
+	}
+	if value == nil {
+		if schema.Type != "" && !schema.Nullable {
+			add("must not be null")
+		}
+		return errs
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		add("must be one of %v", schema.Enum)
+	}
+	switch schema.Type {
+	case "":
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			add("must be a string")
+			return errs
+		}
+		errs = append(errs, d.validateString(schema, s, field)...)
+	case "integer", "number":
+		n, ok := toFloat(value)
+		if !ok {
+			add("must be a %s", schema.Type)
+			return errs
+		}
+		if schema.Type == "integer" && n != float64(int64(n)) {
+			add("must be an integer")
+			return errs
+		}
+		if schema.Minimum != nil && (n < *schema.Minimum || schema.ExclusiveMinimum && n == *schema.Minimum) {
+			add("must be greater than %s%v", orEqual(schema.ExclusiveMinimum), *schema.Minimum)
+		}
+		if schema.Maximum != nil && (n > *schema.Maximum || schema.ExclusiveMaximum && n == *schema.Maximum) {
+			add("must be less than %s%v", orEqual(schema.ExclusiveMaximum), *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			add("must be a boolean")
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			add("must be an array")
+			return errs
+		}
+		if schema.MinItems != nil && uint64(len(items)) < *schema.MinItems {
+			add("must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && uint64(len(items)) > *schema.MaxItems {
+			add("must have at most %d items", *schema.MaxItems)
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				errs = append(errs, d.ValidateValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, i))...)
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			add("must be an object")
+			return errs
+		}
+		errs = append(errs, d.validateObject(schema, object, field)...)
+	}
+	return errs
+}
+
+func (d *Document) matches(schemas []*Schema, value interface{}, field string) int {
+	n := 0
+	for _, s := range schemas {
+		if len(d.ValidateValue(s, value, field)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *Document) validateString(schema *Schema, s, field string) ValidationErrors {
+	var errs ValidationErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	length := uint64(utf8.RuneCountInString(s))
+	if schema.MinLength != nil && length < *schema.MinLength {
+		add("must be at least %d characters", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		add("must be at most %d characters", *schema.MaxLength)
+	}
+	if schema.Pattern != "" {
+		if re := pattern(schema.Pattern); re != nil && !re.MatchString(s) {
+			add("must match %s", schema.Pattern)
+		}
+	}
+	if schema.Format != "" && !validFormat(schema.Format, s) {
+		add("must be a valid %s", schema.Format)
+	}
+	return errs
+}
+
+func (d *Document) validateObject(schema *Schema, object map[string]interface{}, field string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			errs = append(errs, ValidationError{Field: join(field, name), Message: "is required"})
+		}
+	}
+	for name, value := range object {
+		if property, ok := schema.Properties[name]; ok {
+			errs = append(errs, d.ValidateValue(property, value, join(field, name))...)
+			continue
+		}
+		if schema.AdditionalProperties != nil {
+			errs = append(errs, d.ValidateValue(schema.AdditionalProperties, value, join(field, name))...)
+		}
+	}
+	return errs
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func orEqual(exclusive bool) string {
+	if exclusive {
+		return ""
+	}
+	return "or equal to "
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	n, isNumber := toFloat(value)
+	for _, e := range enum {
+		if m, ok := toFloat(e); ok && isNumber && m == n {
+			return true
+		}
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func pattern(expr string) *regexp.Regexp {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	patterns.Store(expr, re)
+	return re
+}
+
+// validFormat is 既知の format を検証する (未知の format は常に true)
+func validFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	case "byte":
+		_, err := base64.StdEncoding.DecodeString(s)
+		return err == nil
+	}
+	return true
+}
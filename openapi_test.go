@@ -0,0 +1,395 @@
+package fwncs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/openapi"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+type openAPIAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type createItemRequest struct {
+	Shop   string          `path:"shop"`
+	DryRun bool            `query:"dry_run,default=false"`
+	Key    string          `header:"X-Api-Key" binding:"required"`
+	Name   string          `json:"name" binding:"required,min=1,max=20"`
+	Kind   string          `json:"kind" binding:"oneof=food book"`
+	Price  int             `json:"price" binding:"gte=0"`
+	Email  string          `json:"email,omitempty" binding:"omitempty,email"`
+	Tags   []string        `json:"tags"`
+	Ship   *openAPIAddress `json:"ship"`
+}
+
+type itemResponse struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func createItem(c fwncs.Context, req *createItemRequest) (*itemResponse, error) {
+	return &itemResponse{Name: req.Name}, nil
+}
+
+func replaceItem(c fwncs.Context, req *createItemRequest) (*itemResponse, error) {
+	return &itemResponse{Name: req.Name}, nil
+}
+
+func TestOpenAPI(t *testing.T) {
+	var issuer string
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":%q}`, issuer, issuer+"/jwks")
+	}))
+	defer idp.Close()
+	issuer = idp.URL
+
+	router := fwncs.New()
+	router.OpenAPI(fwncs.OpenAPIConfig{
+		Path:          "/docs/openapi.json",
+		SwaggerUIPath: "/docs",
+		Info:          openapi.Info{Title: "Shop API", Version: "2.0.0"},
+	})
+	router.HandleTyped(http.MethodPost, "/shops/:shop/items", createItem)
+	router.PUT("/shops/:shop/items", fwncs.Typed(replaceItem))
+	router.GET("/shops/:shop/items/*filepath", func(c fwncs.Context) {})
+	authOption := fwncs.AuthOption{
+		Issuer: issuer,
+		KeyFunc: func(ctx context.Context, jwksURL, kid string) (interface{}, error) {
+			return nil, errors.New("unused")
+		},
+	}
+	admin := router.Group("/admin").Secure(authOption, "scope", "items:delete")
+	admin.DELETE("/items/:id", func(c fwncs.Context) {})
+	router.Secure(authOption, "scope").GET("/me", func(c fwncs.Context) {})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+	rw := serve("/docs/openapi.json")
+	assert.Equal(t, http.StatusOK, rw.Code)
+	var doc openapi.Document
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &doc))
+
+	tt := tests.TestFrames{
+		{Name: "document", Fn: func(t *testing.T) {
+			assert.Equal(t, "3.0.3", doc.OpenAPI)
+			assert.Equal(t, "Shop API", doc.Info.Title)
+			assert.Equal(t, "2.0.0", doc.Info.Version)
+			assert.Len(t, doc.Paths, 4)
+			assert.NotContains(t, doc.Paths, "/docs/openapi.json")
+			assert.NotContains(t, doc.Paths, "/docs")
+		}},
+		{Name: "typed handler", Fn: func(t *testing.T) {
+			op := doc.Paths["/shops/{shop}/items"]["post"]
+			if !assert.NotNil(t, op) {
+				return
+			}
+			assert.Equal(t, "createItem", op.OperationID)
+			params := map[string]*openapi.Parameter{}
+			for _, p := range op.Parameters {
+				params[p.In+":"+p.Name] = p
+			}
+			assert.Len(t, params, 3)
+			assert.True(t, params["path:shop"].Required)
+			assert.Equal(t, "boolean", params["query:dry_run"].Schema.Type)
+			assert.Equal(t, false, params["query:dry_run"].Schema.Default)
+			assert.False(t, params["query:dry_run"].Required)
+			assert.True(t, params["header:X-Api-Key"].Required)
+
+			body := op.RequestBody.Content["application/json"].Schema
+			assert.ElementsMatch(t, []string{"name"}, body.Required)
+			assert.Len(t, body.Properties, 6)
+			assert.NotContains(t, body.Properties, "Shop")
+			assert.EqualValues(t, 1, *body.Properties["name"].MinLength)
+			assert.EqualValues(t, 20, *body.Properties["name"].MaxLength)
+			assert.Equal(t, []interface{}{"food", "book"}, body.Properties["kind"].Enum)
+			assert.EqualValues(t, 0, *body.Properties["price"].Minimum)
+			assert.Equal(t, "email", body.Properties["email"].Format)
+			assert.Equal(t, "string", body.Properties["tags"].Items.Type)
+			assert.Equal(t, "#/components/schemas/openAPIAddress", body.Properties["ship"].Ref)
+			assert.Equal(t, []string{"city"}, doc.Components.Schemas["openAPIAddress"].Required)
+
+			assert.Contains(t, op.Responses, "400")
+			resp := op.Responses["200"].Content["application/json"].Schema
+			assert.Equal(t, "#/components/schemas/itemResponse", resp.Ref)
+			created := doc.Components.Schemas["itemResponse"].Properties["created_at"]
+			assert.Equal(t, "date-time", created.Format)
+			assert.Empty(t, op.Security)
+
+			// PUT などに Typed を渡した場合も Req, Resp を出力する
+			op = doc.Paths["/shops/{shop}/items"]["put"]
+			if assert.NotNil(t, op) {
+				assert.Equal(t, "replaceItem", op.OperationID)
+				assert.Len(t, op.Parameters, 3)
+				assert.Equal(t, body, op.RequestBody.Content["application/json"].Schema)
+				assert.Equal(t, resp, op.Responses["200"].Content["application/json"].Schema)
+			}
+		}},
+		{Name: "path parameters", Fn: func(t *testing.T) {
+			op := doc.Paths["/shops/{shop}/items/{filepath}"]["get"]
+			if !assert.NotNil(t, op) {
+				return
+			}
+			assert.Empty(t, op.OperationID)
+			assert.Len(t, op.Parameters, 2)
+			assert.Equal(t, "filepath", op.Parameters[1].Name)
+			assert.True(t, op.Parameters[1].Required)
+			assert.Nil(t, op.RequestBody)
+			assert.Contains(t, op.Responses, "200")
+		}},
+		{Name: "security", Fn: func(t *testing.T) {
+			scheme := doc.Components.SecuritySchemes["openId"]
+			if assert.NotNil(t, scheme) {
+				assert.Equal(t, "openIdConnect", scheme.Type)
+				assert.Equal(t, issuer+"/.well-known/openid-configuration", scheme.OpenIDConnectURL)
+			}
+			op := doc.Paths["/admin/items/{id}"]["delete"]
+			if !assert.NotNil(t, op) {
+				return
+			}
+			assert.Equal(t, []openapi.SecurityRequirement{{"openId": {"items:delete"}}}, op.Security)
+			assert.Contains(t, op.Responses, "401")
+			assert.Contains(t, op.Responses, "403")
+
+			op = doc.Paths["/me"]["get"]
+			if assert.NotNil(t, op) {
+				assert.Equal(t, []openapi.SecurityRequirement{{"openId": {}}}, op.Security)
+				assert.Contains(t, op.Responses, "401")
+				assert.NotContains(t, op.Responses, "403")
+			}
+		}},
+		{Name: "secure installs auth", Fn: func(t *testing.T) {
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodDelete, "/admin/items/1", nil),
+				httptest.NewRequest(http.MethodGet, "/me", nil),
+			} {
+				rw := httptest.NewRecorder()
+				router.ServeHTTP(rw, req)
+				assert.Equal(t, http.StatusUnauthorized, rw.Code, req.URL.Path)
+			}
+		}},
+		{Name: "route options", Fn: func(t *testing.T) {
+			routes := map[string]fwncs.RouterInfo{}
+			for _, route := range router.Routes() {
+				routes[route.Method+" "+route.Path] = route
+			}
+			assert.Equal(t, []fwncs.RouteSecurity{{Issuer: issuer, Scopes: []string{"items:delete"}}}, routes["DELETE /admin/items/:id"].Security)
+			assert.Empty(t, routes["POST /shops/:shop/items"].Security)
+			assert.True(t, routes["GET /docs/openapi.json"].Hidden)
+			assert.True(t, routes["GET /docs"].Hidden)
+			assert.False(t, routes["GET /shops/:shop/items/*filepath"].Hidden)
+		}},
+		{Name: "swagger ui", Fn: func(t *testing.T) {
+			rw := serve("/docs")
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Contains(t, rw.Header().Get("Content-Type"), "text/html")
+			assert.Contains(t, rw.Body.String(), "swagger-ui-bundle.js")
+			assert.Contains(t, rw.Body.String(), `url: "/docs/openapi.json"`)
+		}},
+	}
+	tt.Run(t)
+}
+
+const petStoreSpec = `
+openapi: 3.0.3
+info:
+  title: Pet Store
+  version: 1.0.0
+servers:
+  - url: https://example.com/api
+paths:
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: integer
+          minimum: 1
+    get:
+      parameters:
+        - name: X-Tenant
+          in: header
+          required: true
+          schema:
+            type: string
+        - name: fields
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+              enum: [name, tag]
+      responses:
+        200:
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+    put:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        204:
+          description: No Content
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          minLength: 1
+        tag:
+          type: string
+          nullable: true
+`
+
+func TestOpenAPIValidator(t *testing.T) {
+	doc, err := openapi.Parse([]byte(petStoreSpec))
+	if !assert.NoError(t, err) {
+		return
+	}
+	newRouter := func(config fwncs.OpenAPIValidatorConfig, logger fwncs.ILogger) *fwncs.Router {
+		config.Document = doc
+		router := fwncs.New(fwncs.LoggerOptions(logger))
+		router.Use(func(c fwncs.Context) {
+			c.SetHeader("X-Frame-Options", "DENY")
+			c.Next()
+		})
+		router.Use(fwncs.OpenAPIValidator(config))
+		api := router.Group("/api")
+		api.GET("/pets/:id", func(c fwncs.Context) {
+			if c.Param("id") == "99" {
+				c.SetHeader("ETag", `"99"`)
+				c.SetHeader("Cache-Control", "max-age=3600")
+				c.SetCookie(&http.Cookie{Name: "pet", Value: "99"})
+				c.JSON(http.StatusOK, map[string]interface{}{"name": "", "owner": "alice"})
+				return
+			}
+			c.JSON(http.StatusOK, map[string]interface{}{"name": "pochi", "tag": nil})
+		})
+		api.PUT("/pets/:id", func(c fwncs.Context) {
+			var pet map[string]interface{}
+			if err := c.ReadJsonBody(&pet); err != nil {
+				c.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			c.Writer().WriteHeader(http.StatusNoContent)
+		})
+		api.GET("/undocumented", func(c fwncs.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+		return router
+	}
+	serve := func(router *fwncs.Router, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Tenant", "shop")
+		if body != "" {
+			req.Header.Set(constant.HeaderContentType, constant.JSON.String())
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	detailFields := func(rw *httptest.ResponseRecorder) []string {
+		var body struct {
+			Details []openapi.ValidationError `json:"details"`
+		}
+		_ = json.Unmarshal(rw.Body.Bytes(), &body)
+		fields := []string{}
+		for _, detail := range body.Details {
+			fields = append(fields, detail.Field)
+		}
+		return fields
+	}
+
+	tt := tests.TestFrames{
+		{Name: "valid request", Fn: func(t *testing.T) {
+			router := newRouter(fwncs.OpenAPIValidatorConfig{}, fwncs.DefaultLogger)
+			rw := serve(router, http.MethodGet, "/api/pets/1?fields=name&fields=tag", "")
+			assert.Equal(t, http.StatusOK, rw.Code)
+			rw = serve(router, http.MethodPut, "/api/pets/1", `{"name":"pochi","tag":null}`)
+			assert.Equal(t, http.StatusNoContent, rw.Code)
+			rw = serve(router, http.MethodGet, "/api/undocumented", "")
+			assert.Equal(t, http.StatusOK, rw.Code)
+		}},
+		{Name: "invalid parameters", Fn: func(t *testing.T) {
+			router := newRouter(fwncs.OpenAPIValidatorConfig{}, fwncs.DefaultLogger)
+			req := httptest.NewRequest(http.MethodGet, "/api/pets/0?fields=owner", nil)
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.ElementsMatch(t, []string{"path.petId", "header.X-Tenant", "query.fields[0]"}, detailFields(rw))
+
+			rw = serve(router, http.MethodGet, "/api/pets/abc", "")
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Equal(t, []string{"path.petId"}, detailFields(rw))
+		}},
+		{Name: "invalid body", Fn: func(t *testing.T) {
+			router := newRouter(fwncs.OpenAPIValidatorConfig{}, fwncs.DefaultLogger)
+			rw := serve(router, http.MethodPut, "/api/pets/1", `{"tag":1,"owner":"alice"}`)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.ElementsMatch(t, []string{"body.name", "body.tag", "body.owner"}, detailFields(rw))
+
+			rw = serve(router, http.MethodPut, "/api/pets/1", "")
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Equal(t, []string{"body"}, detailFields(rw))
+
+			rw = serve(router, http.MethodPut, "/api/pets/1", `{"name":`)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+		}},
+		{Name: "invalid response", Fn: func(t *testing.T) {
+			router := newRouter(fwncs.OpenAPIValidatorConfig{ValidateResponse: true}, fwncs.NewLogger(io.Discard, fwncs.FormatShort, fwncs.FormatDatetime))
+			rw := serve(router, http.MethodGet, "/api/pets/1", "")
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.JSONEq(t, `{"name":"pochi","tag":null}`, rw.Body.String())
+
+			rw = serve(router, http.MethodPut, "/api/pets/1", `{"name":"pochi"}`)
+			assert.Equal(t, http.StatusNoContent, rw.Code)
+
+			rw = serve(router, http.MethodGet, "/api/pets/99", "")
+			assert.Equal(t, http.StatusInternalServerError, rw.Code)
+			assert.ElementsMatch(t, []string{"body.name", "body.owner"}, detailFields(rw))
+			// handler が設定したヘッダーは返さず、handler の前に設定したヘッダーは残す
+			assert.Empty(t, rw.Header().Get("ETag"))
+			assert.Empty(t, rw.Header().Get("Cache-Control"))
+			assert.Empty(t, rw.Header().Values("Set-Cookie"))
+			assert.Equal(t, "DENY", rw.Header().Get("X-Frame-Options"))
+		}},
+		{Name: "warn only", Fn: func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			router := newRouter(fwncs.OpenAPIValidatorConfig{ValidateResponse: true, WarnOnly: true}, fwncs.NewLogger(buf, fwncs.FormatShort, fwncs.FormatDatetime))
+			rw := serve(router, http.MethodGet, "/api/pets/99?fields=owner", "")
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Contains(t, rw.Body.String(), "alice")
+			assert.Contains(t, buf.String(), "query.fields[0]")
+			assert.Contains(t, buf.String(), "body.owner")
+		}},
+	}
+	tt.Run(t)
+}
@@ -0,0 +1,308 @@
+package fwncs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/openapi"
+)
+
+var openAPITemplateParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPIValidatorConfig is OpenAPIValidator の設定
+type OpenAPIValidatorConfig struct {
+	// Document is 検証に使うドキュメント (openapi.Load で読み込む)
+	Document *openapi.Document
+	// ValidateResponse is レスポンスのステータスと JSON の body も検証する (開発環境向け)
+	// 	検証が終わるまでレスポンスをバッファリングするため Stream などとは併用できない
+	ValidateResponse bool
+	// WarnOnly is 違反をエラーにせず Logger().Warning に出力する
+	WarnOnly bool
+}
+
+// OpenAPIValidator is マッチしたルートの operation で path, query, header, cookie パラメータと JSON の request body を検証する
+// 	違反した場合は違反の一覧を Details に設定した 400 を返し、ドキュメントに無いルートは検証しない
+// 	ValidateResponse の場合はレスポンスも検証し、違反した場合は 500 を返す
+func OpenAPIValidator(config OpenAPIValidatorConfig) HandlerFunc {
+	doc := config.Document
+	if doc == nil {
+		panic(errors.New("OpenAPIValidator: Document is nil"))
+	}
+	if doc.Components == nil {
+		doc.Components = &openapi.Components{}
+	}
+	// パラメータ名が異なっても /users/:id と /users/{userId} が対応するように名前を除いたパスで探す
+	paths := map[string]string{}
+	for p := range doc.Paths {
+		paths[openAPITemplateParam.ReplaceAllString(p, "{}")] = p
+	}
+	basePaths := openAPIBasePaths(doc.Servers)
+	findOperation := func(c Context) (string, *openapi.Operation) {
+		routePath, _ := openAPIPath(c.FullPath())
+		routePath = openAPITemplateParam.ReplaceAllString(routePath, "{}")
+		for _, base := range basePaths {
+			if !strings.HasPrefix(routePath, base) {
+				continue
+			}
+			if p, ok := paths[routePath[len(base):]]; ok {
+				return p, doc.Paths[p][strings.ToLower(c.Request().Method)]
+			}
+		}
+		return "", nil
+	}
+	return func(c Context) {
+		docPath, op := findOperation(c)
+		if op == nil {
+			c.Next()
+			return
+		}
+		if errs := validateOpenAPIRequest(c, doc, docPath, op); len(errs) > 0 {
+			if !config.WarnOnly {
+				c.AbortWithError(http.StatusBadRequest, NewHTTPError(http.StatusBadRequest, "request does not match the OpenAPI document").WithDetails(errs))
+				return
+			}
+			c.Logger().Warning(fmt.Sprintf("openapi: %s %s: %v", c.Request().Method, c.Request().URL.Path, errs))
+		}
+		if !config.ValidateResponse {
+			c.Next()
+			return
+		}
+		original := c.Writer()
+		// handler が設定したヘッダーはエラーのレスポンスに含めないように、handler の前のヘッダーを保存しておく
+		header := original.Header().Clone()
+		w := &bufferedResponseWriter{ResponseWriter: original, status: http.StatusOK, size: noWritten}
+		c.SetWriter(w)
+		c.Next()
+		c.SetWriter(original)
+		errs := validateOpenAPIResponse(doc, op, w)
+		if len(errs) > 0 {
+			if !config.WarnOnly {
+				he := NewHTTPError(http.StatusInternalServerError, "response does not match the OpenAPI document").WithDetails(errs)
+				resetHeader(original.Header(), header)
+				c.Logger().Error(he)
+				writeOpenAPIError(c, he)
+				return
+			}
+			c.Logger().Warning(fmt.Sprintf("openapi: %s %s: response: %v", c.Request().Method, c.Request().URL.Path, errs))
+		}
+		w.flush()
+	}
+}
+
+// openAPIBasePaths is servers の URL のパス部分 (/api/v1 など)
+func openAPIBasePaths(servers []openapi.Server) []string {
+	basePaths := []string{}
+	for _, server := range servers {
+		u, err := url.Parse(server.URL)
+		if err != nil {
+			continue
+		}
+		if base := strings.TrimSuffix(u.Path, "/"); base != "" {
+			basePaths = append(basePaths, base)
+		}
+	}
+	return append(basePaths, "")
+}
+
+func validateOpenAPIRequest(c Context, doc *openapi.Document, docPath string, op *openapi.Operation) openapi.ValidationErrors {
+	var errs openapi.ValidationErrors
+	// ルートのパラメータは順番でドキュメントのパラメータ名に対応させる
+	pathValues := map[string]string{}
+	params := c.Params()
+	for i, match := range openAPITemplateParam.FindAllStringSubmatch(docPath, -1) {
+		if i < len(params) {
+			pathValues[match[1]] = params[i].Value
+		}
+	}
+	req := c.Request()
+	for _, p := range op.Parameters {
+		p = doc.Parameter(p)
+		if p == nil {
+			continue
+		}
+		var values []string
+		switch p.In {
+		case "path":
+			if v, ok := pathValues[p.Name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = req.URL.Query()[p.Name]
+		case "header":
+			values = req.Header.Values(p.Name)
+		case "cookie":
+			if cookie, err := req.Cookie(p.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+		errs = append(errs, doc.ValidateParameter(p, values)...)
+	}
+	if body := doc.RequestBody(op.RequestBody); body != nil {
+		errs = append(errs, validateOpenAPIRequestBody(c, doc, body)...)
+	}
+	return errs
+}
+
+func validateOpenAPIRequestBody(c Context, doc *openapi.Document, body *openapi.RequestBody) openapi.ValidationErrors {
+	buf, err := c.Body()
+	if err != nil {
+		return openapi.ValidationErrors{{Field: "body", Message: err.Error()}}
+	}
+	if len(buf) == 0 {
+		if body.Required {
+			return openapi.ValidationErrors{{Field: "body", Message: "is required"}}
+		}
+		return nil
+	}
+	contentType := mediaType(c.Header().Get(constant.HeaderContentType))
+	media, ok := openAPIMediaType(body.Content, contentType)
+	if !ok {
+		return openapi.ValidationErrors{{Field: "body", Message: fmt.Sprintf("content type %q is not allowed", contentType)}}
+	}
+	return validateOpenAPIJSON(doc, media, contentType, buf)
+}
+
+func validateOpenAPIResponse(doc *openapi.Document, op *openapi.Operation, w *bufferedResponseWriter) openapi.ValidationErrors {
+	status := strconv.Itoa(w.status)
+	resp, ok := op.Responses[status]
+	if !ok {
+		resp, ok = op.Responses[status[:1]+"XX"]
+	}
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return openapi.ValidationErrors{{Field: "status", Message: fmt.Sprintf("%s is not documented", status)}}
+	}
+	resp = doc.Response(resp)
+	if resp == nil || len(resp.Content) == 0 || w.buf.Len() == 0 {
+		return nil
+	}
+	contentType := mediaType(w.Header().Get(constant.HeaderContentType))
+	media, ok := openAPIMediaType(resp.Content, contentType)
+	if !ok {
+		return openapi.ValidationErrors{{Field: "body", Message: fmt.Sprintf("content type %q is not documented", contentType)}}
+	}
+	return validateOpenAPIJSON(doc, media, contentType, w.buf.Bytes())
+}
+
+// validateOpenAPIJSON is JSON の場合だけ body を schema で検証する
+func validateOpenAPIJSON(doc *openapi.Document, media *openapi.MediaType, contentType string, buf []byte) openapi.ValidationErrors {
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+	if contentType != constant.JSONAscii.String() && !strings.HasSuffix(contentType, "+json") {
+		return nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return openapi.ValidationErrors{{Field: "body", Message: "invalid JSON: " + err.Error()}}
+	}
+	return doc.ValidateValue(media.Schema, v, "body")
+}
+
+// openAPIMediaType is content から contentType に一致する MediaType を探す (type/*, */* も一致する)
+// 	content が空の場合はどの contentType も許可する
+func openAPIMediaType(content map[string]*openapi.MediaType, contentType string) (*openapi.MediaType, bool) {
+	if len(content) == 0 {
+		return nil, true
+	}
+	keys := []string{contentType, "*/*"}
+	if idx := strings.IndexByte(contentType, '/'); idx >= 0 {
+		keys = []string{contentType, contentType[:idx] + "/*", "*/*"}
+	}
+	for _, key := range keys {
+		for mt, media := range content {
+			if mediaType(mt) == key {
+				return media, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// resetHeader is h を original の状態に戻す
+func resetHeader(h, original http.Header) {
+	for key := range h {
+		delete(h, key)
+	}
+	for key, values := range original {
+		h[key] = values
+	}
+}
+
+// writeOpenAPIError is Router.ErrorHandler で err だけを返却する
+func writeOpenAPIError(c Context, err error) {
+	handler := DefaultErrorHandler
+	if cc, ok := c.(*_context); ok && cc.router.ErrorHandler != nil {
+		handler = cc.router.ErrorHandler
+	}
+	handler(c, []error{err})
+}
+
+// bufferedResponseWriter is レスポンスを検証するまで書き込みを保留する
+type bufferedResponseWriter struct {
+	ResponseWriter
+	buf    bytes.Buffer
+	status int
+	size   int
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+	}
+}
+
+func (w *bufferedResponseWriter) Write(buf []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.buf.Write(buf)
+	w.size += n
+	return n, err
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedResponseWriter) Flush() {
+	w.WriteHeaderNow()
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// flush is 保留したレスポンスを書き込む
+func (w *bufferedResponseWriter) flush() {
+	if w.Written() || w.status != http.StatusOK {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
@@ -0,0 +1,159 @@
+package fwncs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrMissingParameter = errors.New("missing parameter")
+
+// ParamError is 型付きアクセサで値の取得、変換に失敗した際のエラー
+// 	呼び出し元の入力不備なので Status は 400 を返す
+type ParamError struct {
+	// Source is path (URL パラメータ) か query (クエリ) のどちらか
+	Source string
+	Name   string
+	Value  string
+	Err    error
+}
+
+func (e *ParamError) Error() string {
+	if errors.Is(e.Err, ErrMissingParameter) {
+		return fmt.Sprintf("%s parameter %q is required", e.Source, e.Name)
+	}
+	return fmt.Sprintf("%s parameter %q has invalid value %q: %v", e.Source, e.Name, e.Value, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParamError) Status() int {
+	return http.StatusBadRequest
+}
+
+const (
+	paramSourcePath  = "path"
+	paramSourceQuery = "query"
+)
+
+func lookupParam(source, name, value string, ok bool) (string, error) {
+	if !ok || value == "" {
+		return "", &ParamError{Source: source, Name: name, Err: ErrMissingParameter}
+	}
+	return value, nil
+}
+
+func parseInt64(source, name, value string, ok bool, bitSize int) (int64, error) {
+	value, err := lookupParam(source, name, value, ok)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		return 0, &ParamError{Source: source, Name: name, Value: value, Err: err}
+	}
+	return i, nil
+}
+
+func (c *_context) queryValues() map[string][]string {
+	if c.query == nil {
+		c.query = c.req.URL.Query()
+	}
+	return c.query
+}
+
+func (c *_context) getQuery(name string) (string, bool) {
+	if values, ok := c.queryValues()[name]; ok && len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+func (c *_context) ParamInt(name string) (int, error) {
+	value, ok := c.params.Get(name)
+	i, err := parseInt64(paramSourcePath, name, value, ok, 0)
+	return int(i), err
+}
+
+func (c *_context) ParamInt64(name string) (int64, error) {
+	value, ok := c.params.Get(name)
+	return parseInt64(paramSourcePath, name, value, ok, 64)
+}
+
+func (c *_context) QueryInt(name string) (int, error) {
+	value, ok := c.getQuery(name)
+	i, err := parseInt64(paramSourceQuery, name, value, ok, 0)
+	return int(i), err
+}
+
+func (c *_context) QueryInt64(name string) (int64, error) {
+	value, ok := c.getQuery(name)
+	return parseInt64(paramSourceQuery, name, value, ok, 64)
+}
+
+func (c *_context) QueryBool(name string) (bool, error) {
+	value, ok := c.getQuery(name)
+	value, err := lookupParam(paramSourceQuery, name, value, ok)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, &ParamError{Source: paramSourceQuery, Name: name, Value: value, Err: err}
+	}
+	return b, nil
+}
+
+func (c *_context) QueryTime(name string, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	value, ok := c.getQuery(name)
+	value, err := lookupParam(paramSourceQuery, name, value, ok)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, &ParamError{Source: paramSourceQuery, Name: name, Value: value, Err: err}
+	}
+	return t, nil
+}
+
+func (c *_context) QueryArray(name string) []string {
+	return c.queryValues()[name]
+}
+
+func (c *_context) QueryMap(name string) map[string]string {
+	return valuesToMap(c.queryValues(), name)
+}
+
+func (c *_context) PostFormArray(name string) []string {
+	if c.req.PostForm == nil {
+		if err := c.req.ParseMultipartForm(c.router.MaxMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			c.Error(err)
+			c.abortIfTooLarge(err)
+		}
+	}
+	return c.req.PostForm[name]
+}
+
+// valuesToMap is name[key]=value 形式の値を key をキーとした map にする
+func valuesToMap(values map[string][]string, name string) map[string]string {
+	mp := make(map[string]string)
+	prefix := name + "["
+	for key, value := range values {
+		if !strings.HasPrefix(key, prefix) || len(value) == 0 {
+			continue
+		}
+		if end := strings.IndexByte(key[len(prefix):], ']'); end > 0 {
+			mp[key[len(prefix):len(prefix)+end]] = value[0]
+		}
+	}
+	return mp
+}
@@ -40,10 +40,12 @@ func Permission(permissionClaim string, permissions ...string) HandlerFunc {
 		token, ok := c.Get(AuthKey).(*jwt.Token)
 		if !ok {
 			c.AbortWithStatus(http.StatusForbidden)
+			return
 		}
 		mpClaims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
 			c.AbortWithStatus(http.StatusForbidden)
+			return
 		}
 		scopes := getScope(mpClaims[permissionClaim])
 		for _, scope := range scopes {
@@ -52,13 +54,11 @@ func Permission(permissionClaim string, permissions ...string) HandlerFunc {
 			}
 		}
 		if mpPermission.IsDenied() {
-			c.AbortWithStatus(http.StatusForbidden)
-		}
-		if !c.IsSkip() {
-			c.Next()
-		} else {
 			c.SetHeader("WWW-Authenticate", "Bearer error=\"insufficient_scope\"")
+			c.AbortWithStatus(http.StatusForbidden)
+			return
 		}
+		c.Next()
 	}
 }
 
@@ -0,0 +1,192 @@
+package fwncs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs/render"
+)
+
+// ProblemTypeBlank is type を省略した場合の値 (RFC 7807 4.2)
+const ProblemTypeBlank = "about:blank"
+
+// Problem is RFC 7807 の problem details
+// 	Extensions は type, title, status, detail, instance と同じ階層に出力する
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// NewProblem is type が about:blank、title がステータスの説明の Problem を返す
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   ProblemTypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WithType is 問題の種類を識別する URI を設定する
+func (p *Problem) WithType(typ, title string) *Problem {
+	p.Type = typ
+	if title != "" {
+		p.Title = title
+	}
+	return p
+}
+
+// WithInstance is 発生箇所を識別する URI を設定する
+func (p *Problem) WithInstance(instance string) *Problem {
+	p.Instance = instance
+	return p
+}
+
+// With is extension member を追加する
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("status=%d, title=%v", p.Status, p.Title)
+	}
+	return fmt.Sprintf("status=%d, title=%v, detail=%v", p.Status, p.Title, p.Detail)
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	mp := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		mp[key] = value
+	}
+	typ := p.Type
+	if typ == "" {
+		typ = ProblemTypeBlank
+	}
+	mp["type"] = typ
+	if p.Title != "" {
+		mp["title"] = p.Title
+	}
+	if p.Status != 0 {
+		mp["status"] = p.Status
+	}
+	if p.Detail != "" {
+		mp["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		mp["instance"] = p.Instance
+	}
+	return json.Marshal(mp)
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	mp := map[string]interface{}{}
+	if err := json.Unmarshal(data, &mp); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for key, value := range mp {
+		switch key {
+		case "type":
+			p.Type, _ = value.(string)
+		case "title":
+			p.Title, _ = value.(string)
+		case "status":
+			if status, ok := value.(float64); ok {
+				p.Status = int(status)
+			}
+		case "detail":
+			p.Detail, _ = value.(string)
+		case "instance":
+			p.Instance, _ = value.(string)
+		default:
+			p.With(key, value)
+		}
+	}
+	return nil
+}
+
+// ToProblem is err を Problem に変換する
+// 	HTTPError の ErrorCode, Details は extension member の error_code, errors になる
+// 	ステータスを持たないエラーは 500 になり、エラーの内容は detail に含めない
+func ToProblem(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	he := ToHTTPError(err)
+	detail := he.Message
+	if detail == http.StatusText(he.Code) {
+		detail = ""
+	}
+	p = NewProblem(he.Code, detail)
+	if he.ErrorCode != "" {
+		p.With("error_code", he.ErrorCode)
+	}
+	if he.Details != nil {
+		p.With("errors", he.Details)
+	}
+	return p
+}
+
+// ProblemErrorHandler is 最初のエラーを application/problem+json で返却する
+// 	instance にはリクエストのパスを設定する
+func ProblemErrorHandler(c Context, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	p := contextProblem(c, errs[0])
+	if p.Status >= http.StatusInternalServerError {
+		for _, err := range errs {
+			c.Logger().Error(err)
+		}
+	}
+	c.Problem(p)
+}
+
+func contextProblem(c Context, err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	p = ToProblem(contextHTTPError(c, err))
+	if p.Instance == "" {
+		p.Instance = c.Request().URL.Path
+	}
+	return p
+}
+
+// useProblemDetails is Router.ProblemDetails が有効かを返す
+func useProblemDetails(c Context) bool {
+	cc, ok := c.(*_context)
+	return ok && cc.router.ProblemDetails
+}
+
+func (c *_context) Problem(p *Problem) {
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	c.Render(status, render.ProblemJSON{Data: p})
+}
+
+func (c *_context) AbortWithProblem(p *Problem) {
+	if !c.IsSkip() {
+		c.Skip()
+		c.Problem(p)
+	}
+}
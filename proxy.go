@@ -216,19 +216,19 @@ func ProxyWithConfig(config ProxyConfig) HandlerFunc {
 			c.AbortWithStatusAndErrorMessage(http.StatusInternalServerError, err)
 			return
 		}
-		if req.Header.Get(constant.HeaderXRealIP) == "" {
-			req.Header.Set(constant.HeaderXRealIP, c.ClientIP())
-		}
-		if req.Header.Get(constant.HeaderXForwardedProto) == "" {
-			req.Header.Set(constant.HeaderXForwardedProto, c.Scheme())
-		}
+		// 転送ヘッダーを追加しても後続の handler の c.Request() が変わらないように複製する
+		req = req.Clone(req.Context())
+		// ClientIP, Scheme は信頼するプロキシのヘッダーのみ参照するため、クライアントが送った値は上書きする
+		req.Header.Set(constant.HeaderXRealIP, c.ClientIP())
+		req.Header.Set(constant.HeaderXForwardedProto, c.Scheme())
+		appendForwarded(req)
 		if c.IsWebSocket() && req.Header.Get(constant.HeaderXForwardedFor) == "" { // For HTTP, it is automatically set by Go HTTP reverse proxy.
 			req.Header.Set(constant.HeaderXForwardedFor, c.ClientIP())
 		}
 		switch {
 		case c.IsWebSocket():
 			proxyRaw(t, c).ServeHTTP(c.Writer(), req)
-		case req.Header.Get(constant.HeaderAccept) == "text/event-stream":
+		case req.Header.Get(constant.HeaderAccept) == constant.EventStream.String():
 		default:
 			proxyHTTP(t, c, config).ServeHTTP(c.Writer(), req)
 		}
@@ -302,3 +302,17 @@ func proxyHTTP(t *ProxyTarget, c Context, config ProxyConfig) http.Handler {
 	proxy.ModifyResponse = config.ModifyResponse
 	return proxy
 }
+
+// appendForwarded is このプロキシが受け取ったリクエストの情報を Forwarded ヘッダーに追加する (RFC 7239)
+// 	req は送信用に複製したリクエストでなければならない
+func appendForwarded(req *http.Request) {
+	element := ForwardedElement{For: "unknown", Host: req.Host, Proto: "http"}
+	if ip := remoteIP(req); ip != nil {
+		element.For = ForwardedNode(ip.String())
+	}
+	if req.TLS != nil {
+		element.Proto = "https"
+	}
+	values := append(req.Header.Values(constant.HeaderForwarded), element.String())
+	req.Header.Set(constant.HeaderForwarded, strings.Join(values, ", "))
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
 	"github.com/n-creativesystem/go-fwncs/tests"
 	"github.com/stretchr/testify/assert"
 )
@@ -129,6 +130,32 @@ func TestProxy(t *testing.T) {
 	tt.Run(t)
 }
 
+func TestProxyForwarded(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get(constant.HeaderForwarded))
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	router := fwncs.New()
+	var header http.Header
+	router.Use(func(c fwncs.Context) {
+		c.Next()
+		header = c.Request().Header
+	})
+	router.Use(fwncs.Proxy(fwncs.NewRoundRobinBalancer([]*fwncs.ProxyTarget{{Name: "upstream", URL: u}})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	req.Host = "example.com"
+	req.Header.Set(constant.HeaderForwarded, "for=192.0.2.60;proto=https")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, `for=192.0.2.60;proto=https, for="[2001:db8::1]";host=example.com;proto=http`, rec.Body.String())
+	// 受け取ったリクエストのヘッダーは変更しない
+	assert.Equal(t, []string{"for=192.0.2.60;proto=https"}, header.Values(constant.HeaderForwarded))
+	assert.Empty(t, header.Get(constant.HeaderXRealIP))
+}
+
 func TestStaticWeightingLoadBalancer(t *testing.T) {
 	targets := []*fwncs.ProxyTarget{
 		{
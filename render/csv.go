@@ -0,0 +1,334 @@
+package render
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	csvColumns sync.Map
+)
+
+// CSVOptions is CSV, CSVStream の書式
+type CSVOptions struct {
+	// Comma is 区切り文字 (0 の場合は ,、\t の場合は TSV になる)
+	Comma rune
+	// Header is ヘッダー行 (nil の場合は構造体の csv タグかフィールド名を使う)
+	Header []string
+	// NoHeader is ヘッダー行を書き込まない
+	NoHeader bool
+	// BOM is 先頭に UTF-8 の BOM を付ける (Excel で文字化けさせないため)
+	// 	Encoding を指定した場合は付けない
+	BOM bool
+	// Encoding is 出力する文字コード (nil の場合は UTF-8)
+	// 	Shift_JIS の場合は japanese.ShiftJIS で、変換できない文字はエラーにせず文字コードの置換文字 (Shift_JIS は 0x1A) にする
+	Encoding encoding.Encoding
+}
+
+// CSV is Rows を CSV で書き込む
+// 	Rows は構造体 (またはそのポインタ) か []string のスライス
+// 	構造体は csv タグを列名にし (- の場合は出力しない)、time.Time は time_format タグの書式 (デフォルトは RFC3339) にする
+type CSV struct {
+	CSVOptions
+	Rows interface{}
+}
+
+func (r CSV) Render(w http.ResponseWriter) error {
+	rows := reflect.ValueOf(r.Rows)
+	if rows.Kind() != reflect.Slice && rows.Kind() != reflect.Array {
+		return fmt.Errorf("render: CSV rows must be a slice, got %T", r.Rows)
+	}
+	r.WriteContentType(w)
+	enc := newCSVEncoder(w, r.CSVOptions)
+	enc.header(rows.Type().Elem())
+	for i := 0; i < rows.Len(); i++ {
+		if err := enc.encode(rows.Index(i)); err != nil {
+			return err
+		}
+	}
+	return enc.close()
+}
+
+func (r CSV) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set(constant.HeaderContentType, r.contentType())
+}
+
+// CSVStream is Items (任意の要素型のチャネルか Iterator) の値を 1 行ずつ CSV で書き込む
+// 	FlushInterval 毎に Flush し、Context が Done になると止める
+type CSVStream struct {
+	CSVOptions
+	Context       context.Context
+	Items         interface{}
+	FlushInterval time.Duration
+}
+
+func (r CSVStream) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	enc := newCSVEncoder(w, r.CSVOptions)
+	if t := reflect.TypeOf(r.Items); t != nil && t.Kind() == reflect.Chan {
+		enc.header(t.Elem())
+	}
+	err := writeStream(csvFlushWriter{ResponseWriter: w, enc: enc}, r.Context, r.Items, r.FlushInterval, nil, func(_ int, v interface{}) error {
+		return enc.encode(reflect.ValueOf(v))
+	})
+	if err != nil {
+		return err
+	}
+	return enc.close()
+}
+
+func (r CSVStream) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set(constant.HeaderContentType, r.contentType())
+}
+
+// csvFlushWriter is Flush の前に csv.Writer のバッファを書き込む
+type csvFlushWriter struct {
+	http.ResponseWriter
+	enc *csvEncoder
+}
+
+func (w csvFlushWriter) Flush() {
+	w.enc.writer.Flush()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (o CSVOptions) contentType() string {
+	contentType := constant.CSV.String()
+	if o.Comma == '\t' {
+		contentType = constant.TSV.String()
+	}
+	charset := "utf-8"
+	if o.Encoding != nil {
+		name, err := ianaindex.MIME.Name(o.Encoding)
+		if err != nil {
+			return contentType
+		}
+		charset = name
+	}
+	return contentType + "; charset=" + charset
+}
+
+type csvColumn struct {
+	index  []int
+	name   string
+	layout string
+}
+
+type csvEncoder struct {
+	options     CSVOptions
+	out         io.Writer
+	writer      *csv.Writer
+	wroteHeader bool
+	columns     []csvColumn
+	record      []string
+}
+
+func newCSVEncoder(w io.Writer, options CSVOptions) *csvEncoder {
+	enc := &csvEncoder{options: options, out: w}
+	if options.Encoding != nil {
+		enc.out = transform.NewWriter(w, encoding.ReplaceUnsupported(options.Encoding.NewEncoder()))
+	} else if options.BOM {
+		enc.out = &bomWriter{Writer: w}
+	}
+	enc.writer = csv.NewWriter(enc.out)
+	if options.Comma != 0 {
+		enc.writer.Comma = options.Comma
+	}
+	return enc
+}
+
+// header is t が構造体の場合に列を決め、ヘッダー行を書き込む
+// 	t が interface の場合は最初の値の型で決める
+func (e *csvEncoder) header(t reflect.Type) {
+	if e.wroteHeader || t != nil && t.Kind() == reflect.Interface {
+		return
+	}
+	e.wroteHeader = true
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	header := e.options.Header
+	if t != nil && t.Kind() == reflect.Struct && t != timeType {
+		e.columns = csvStructColumns(t)
+		if header == nil {
+			for _, column := range e.columns {
+				header = append(header, column.name)
+			}
+		}
+	}
+	if header != nil && !e.options.NoHeader {
+		_ = e.writer.Write(header)
+	}
+}
+
+func (e *csvEncoder) encode(v reflect.Value) error {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() == reflect.Interface {
+		return fmt.Errorf("render: CSV row must not be nil")
+	}
+	e.header(v.Type())
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	e.record = e.record[:0]
+	switch {
+	case e.columns != nil && v.Kind() == reflect.Struct:
+		for _, column := range e.columns {
+			e.record = append(e.record, csvValue(fieldByIndex(v, column.index), column.layout))
+		}
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.record = append(e.record, csvValue(v.Index(i), ""))
+		}
+	default:
+		return fmt.Errorf("render: CSV row must be a struct or a slice, got %s", v.Type())
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) close() error {
+	e.header(nil)
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return err
+	}
+	if closer, ok := e.out.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// bomWriter is 最初の書き込みの前に BOM を書き込む
+type bomWriter struct {
+	io.Writer
+	wrote bool
+}
+
+func (w *bomWriter) Write(buf []byte) (int, error) {
+	if !w.wrote {
+		w.wrote = true
+		if _, err := w.Writer.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return 0, err
+		}
+	}
+	return w.Writer.Write(buf)
+}
+
+// csvStructColumns is encoding/json と同様に埋め込みの構造体を展開して列を作る
+func csvStructColumns(t reflect.Type) []csvColumn {
+	if columns, ok := csvColumns.Load(t); ok {
+		return columns.([]csvColumn)
+	}
+	columns := []csvColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("csv"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" {
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				for _, column := range csvStructColumns(ft) {
+					column.index = append([]int{i}, column.index...)
+					columns = append(columns, column)
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, csvColumn{index: []int{i}, name: name, layout: field.Tag.Get("time_format")})
+	}
+	csvColumns.Store(t, columns)
+	return columns
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex と異なり nil の埋め込みポインタで panic しない
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// csvValue is 値を CSV の 1 項目の文字列にする (nil は空文字)
+func csvValue(v reflect.Value, layout string) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Type() == timeType {
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return v.Interface().(time.Time).Format(layout)
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case interface{ MarshalText() ([]byte, error) }:
+			if buf, err := x.MarshalText(); err == nil {
+				return string(buf)
+			}
+		case fmt.Stringer:
+			return x.String()
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	if v.CanInterface() {
+		return fmt.Sprint(v.Interface())
+	}
+	return ""
+}
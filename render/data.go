@@ -0,0 +1,24 @@
+package render
+
+import (
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (r Data) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r Data) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		w.Header().Set(constant.HeaderContentType, r.ContentType)
+	}
+}
@@ -3,8 +3,12 @@ package render
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"text/template"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/n-creativesystem/go-fwncs/bytesconv"
 	"github.com/n-creativesystem/go-fwncs/constant"
@@ -27,13 +31,52 @@ func WriteJson(w http.ResponseWriter, data interface{}) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
+// ProblemJSON is RFC 7807 の application/problem+json
+type ProblemJSON struct {
+	Data interface{}
+}
+
+func (r ProblemJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+func (r ProblemJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.ProblemJSON)
+}
+
+// AsciiJSON is ASCII 以外の文字を \uXXXX にエスケープした JSON
 type AsciiJSON struct {
 	Data interface{}
 }
 
 func (r AsciiJSON) Render(w http.ResponseWriter) error {
+	buf, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
 	r.WriteContentType(w)
-	return json.NewEncoder(w).Encode(r.Data)
+	_, err = w.Write(escapeNonASCII(buf))
+	return err
+}
+
+// escapeNonASCII is ASCII 以外の文字を \uXXXX (BMP 外はサロゲートペア) にする
+// 	JSON の構文に使われる文字は全て ASCII なので、文字列の中だけが対象になる
+func escapeNonASCII(buf []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(buf))
+	for _, r := range string(buf) {
+		if r < utf8.RuneSelf {
+			out.WriteByte(byte(r))
+			continue
+		}
+		if r1, r2 := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+			fmt.Fprintf(&out, "\\u%04x\\u%04x", r1, r2)
+			continue
+		}
+		fmt.Fprintf(&out, "\\u%04x", r)
+	}
+	return out.Bytes()
 }
 
 func (r AsciiJSON) WriteContentType(w http.ResponseWriter) {
@@ -97,3 +140,51 @@ func (r JSONP) Render(w http.ResponseWriter) error {
 func (r JSONP) WriteContentType(w http.ResponseWriter) {
 	writeContentType(w, constant.JSONP)
 }
+
+// PureJSON is HTML の特殊文字 (<, >, &) をエスケープしない JSON
+type PureJSON struct {
+	Data interface{}
+}
+
+func (r PureJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	return e.Encode(r.Data)
+}
+
+func (r PureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.JSON)
+}
+
+// DefaultSecureJSONPrefix is SecureJSON の Prefix が空の場合に使われる
+const DefaultSecureJSONPrefix = "while(1);"
+
+// SecureJSON is JSON Hijacking 対策としてトップレベルが配列の場合は Prefix を先頭に付ける
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	buf, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	r.WriteContentType(w)
+	if bytes.HasPrefix(buf, []byte("[")) {
+		prefix := r.Prefix
+		if prefix == "" {
+			prefix = DefaultSecureJSONPrefix
+		}
+		if _, err := w.Write(bytesconv.StringToBytes(prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.JSON)
+}
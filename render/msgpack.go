@@ -0,0 +1,31 @@
+package render
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPack is MessagePack で返却する
+// 	binding.MsgPack と同様に msgpack タグが無い場合は json タグを使う
+type MsgPack struct {
+	Data interface{}
+}
+
+func (r MsgPack) Render(w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	if err := encoder.Encode(r.Data); err != nil {
+		return err
+	}
+	r.WriteContentType(w)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (r MsgPack) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.MSGPACK)
+}
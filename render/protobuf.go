@@ -0,0 +1,32 @@
+package render
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoBuf is Protocol Buffers で返却する (Data は proto.Message でなければならない)
+type ProtoBuf struct {
+	Data interface{}
+}
+
+func (r ProtoBuf) Render(w http.ResponseWriter) error {
+	msg, ok := r.Data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("render: ProtoBuf requires proto.Message, got %T", r.Data)
+	}
+	buf, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	r.WriteContentType(w)
+	_, err = w.Write(buf)
+	return err
+}
+
+func (r ProtoBuf) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.ProtocolBuffer)
+}
@@ -20,8 +20,20 @@ var (
 	_ Render = Text{}
 	_ Render = JSON{}
 	_ Render = AsciiJSON{}
+	_ Render = PureJSON{}
+	_ Render = SecureJSON{}
 	_ Render = JSONP{}
 	_ Render = IndentJSON{}
 	_ Render = Redirect{}
 	_ Render = TemplateRender{}
+	_ Render = XML{}
+	_ Render = MsgPack{}
+	_ Render = ProtoBuf{}
+	_ Render = SSEvent{}
+	_ Render = NDJSON{}
+	_ Render = JSONArray{}
+	_ Render = CSV{}
+	_ Render = CSVStream{}
+	_ Render = ProblemJSON{}
+	_ Render = Data{}
 )
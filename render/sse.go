@@ -0,0 +1,86 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+// SSEvent is Server-Sent Events の 1 イベント
+// 	Data が string, []byte 以外の場合は JSON にエンコードする
+type SSEvent struct {
+	ID    string
+	Event string
+	Retry uint
+	Data  interface{}
+}
+
+func (r SSEvent) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return r.Encode(w)
+}
+
+func (r SSEvent) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	writeContentType(w, constant.EventStream)
+	if header.Get(constant.HeaderCacheControl) == "" {
+		header.Set(constant.HeaderCacheControl, "no-cache")
+	}
+}
+
+// Encode is イベントを 1 回の Write で w に書き込む
+func (r SSEvent) Encode(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	if r.ID != "" {
+		writeSSEField(buf, "id", r.ID)
+	}
+	if r.Event != "" {
+		writeSSEField(buf, "event", r.Event)
+	}
+	if r.Retry > 0 {
+		writeSSEField(buf, "retry", strconv.FormatUint(uint64(r.Retry), 10))
+	}
+	data, err := sseData(r.Data)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(data, "\n") {
+		writeSSEField(buf, "data", line)
+	}
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func sseData(data interface{}) (string, error) {
+	switch v := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// writeSSEField is 改行を含むとイベントが壊れるため CR, LF を取り除いて書き込む
+func writeSSEField(buf *bytes.Buffer, name, value string) {
+	value = strings.NewReplacer("\r\n", "", "\r", "", "\n", "").Replace(value)
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
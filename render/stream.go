@@ -0,0 +1,161 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+// Iterator is 次の値と値があるかを返す (false を返すと終了する)
+type Iterator func() (interface{}, bool, error)
+
+// NDJSON is Items の値を 1 行ずつ JSON で書き込む (application/x-ndjson)
+type NDJSON struct {
+	// Context is Done になると書き込みを止める (クライアントの切断の検知に使う)
+	Context context.Context
+	// Items is 任意の要素型のチャネルか Iterator
+	// 	チャネルは close されるまで読み込むので、送信側も Context の Done で止めること
+	Items interface{}
+	// FlushInterval is Flush する間隔 (0 の場合は値毎に Flush する)
+	FlushInterval time.Duration
+}
+
+func (r NDJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return writeStream(w, r.Context, r.Items, r.FlushInterval, nil, func(_ int, v interface{}) error {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(buf, '\n'))
+		return err
+	})
+}
+
+func (r NDJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.NDJSON)
+}
+
+// JSONArray is Items の値を 1 つの JSON の配列として順番に書き込む
+// 	途中で止まった場合は ] を書き込まないので、クライアントは不完全な JSON として検知できる
+type JSONArray struct {
+	Context       context.Context
+	Items         interface{}
+	FlushInterval time.Duration
+}
+
+func (r JSONArray) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	err := writeStream(w, r.Context, r.Items, r.FlushInterval, []byte{'['}, func(i int, v interface{}) error {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf = append([]byte{','}, buf...)
+		}
+		_, err = w.Write(buf)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte{']'})
+	return err
+}
+
+func (r JSONArray) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.JSON)
+}
+
+// writeStream is prefix を書き込んだ後、items の値毎に write を呼び出し、interval 毎と終了時に Flush する
+// 	ctx が Done になった場合は ctx.Err() を返す
+func writeStream(w http.ResponseWriter, ctx context.Context, items interface{}, interval time.Duration, prefix []byte, write func(i int, v interface{}) error) error {
+	next, ok := items.(func() (interface{}, bool, error))
+	if it, isIterator := items.(Iterator); isIterator {
+		next, ok = it, true
+	}
+	ch := reflect.ValueOf(items)
+	if !ok && (ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0) {
+		return fmt.Errorf("render: items must be a receive channel or Iterator, got %T", items)
+	}
+	if len(prefix) > 0 {
+		if _, err := w.Write(prefix); err != nil {
+			return err
+		}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	lastFlush := time.Now()
+	wrote := func(i int, v interface{}) error {
+		if err := write(i, v); err != nil {
+			return err
+		}
+		if interval <= 0 || time.Since(lastFlush) >= interval {
+			flush()
+			lastFlush = time.Now()
+		}
+		return nil
+	}
+	defer flush()
+	if next != nil {
+		return iterate(ctx, next, wrote)
+	}
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	// 値が届かない間も書き込んだ分を送るために interval 毎に Flush する
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ticker.C)})
+	}
+	for i := 0; ; {
+		chosen, v, ok := reflect.Select(cases)
+		switch chosen {
+		case 0:
+			return ctx.Err()
+		case 1:
+			if !ok {
+				return nil
+			}
+			if err := wrote(i, v.Interface()); err != nil {
+				return err
+			}
+			i++
+		default:
+			flush()
+			lastFlush = time.Now()
+		}
+	}
+}
+
+func iterate(ctx context.Context, next func() (interface{}, bool, error), write func(i int, v interface{}) error) error {
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		v, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if err := write(i, v); err != nil {
+			return err
+		}
+	}
+}
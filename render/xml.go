@@ -0,0 +1,21 @@
+package render
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+type XML struct {
+	Data interface{}
+}
+
+func (r XML) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+func (r XML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, constant.XML)
+}
@@ -6,20 +6,57 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/render"
 )
 
 type RouterInfo struct {
 	Method      string
 	Path        string
 	HandlerName string
+	// Request, Response is Typed の handler を登録した場合の Req, Resp の型
+	Request  reflect.Type
+	Response reflect.Type
+	// Security is SecurityOption で設定したルートの認証 (OpenAPI の security になる)
+	Security []RouteSecurity
+	// Hidden is OpenAPI のドキュメントに含めない
+	Hidden bool
+}
+
+// RouteSecurity is Auth の issuer と Permission で必要な scope
+type RouteSecurity struct {
+	Issuer string
+	Scopes []string
+}
+
+// RouteOption is ルートの登録時に RouterInfo を設定する
+type RouteOption func(route *RouterInfo)
+
+// SecurityOption is ルートが issuer の Auth と scopes の Permission で保護されていることを記録する
+// 	ミドルウェアは追加しないので、通常は Auth, Permission と一緒に設定する Router.Secure を使う
+func SecurityOption(issuer string, scopes ...string) RouteOption {
+	return func(route *RouterInfo) {
+		route.Security = append(route.Security, RouteSecurity{Issuer: issuer, Scopes: append([]string{}, scopes...)})
+	}
+}
+
+// HiddenOption is ルートを OpenAPI のドキュメントに含めない
+func HiddenOption() RouteOption {
+	return func(route *RouterInfo) {
+		route.Hidden = true
+	}
 }
 
 type MapRouterInformations map[string][]RouterInfo
@@ -55,14 +92,27 @@ type Router struct {
 	RemoveExtraSlash       bool
 	RedirectFixedPath      bool
 	HandleMethodNotAllowed bool
+	MaxBodySize            int64
+	MaxMultipartMemory     int64
+	StreamKeepAlive        time.Duration
+	StreamFlushInterval    time.Duration
+	ErrorHandler           ErrorHandlerFunc
+	ProblemDetails         bool
+	ErrorPage              ErrorPage
+	RemoteIPHeaders        []string
+	SchemeHeaders          []string
+	HostHeaders            []string
+	SecureJSONPrefix       string
 	group                  string
 	logger                 ILogger
+	trustedProxies         []*net.IPNet
 	use                    []HandlerFunc
+	options                []RouteOption
 	routes                 MapRouterInformations
 	pool                   *sync.Pool
-	usePool                *sync.Pool
 	trees                  map[string]nodelocation
 	pathHandlers           map[string]pathHandler
+	html                   *htmlEngine
 	allNotFound            HandlerFuncChain
 	allNoMethod            HandlerFuncChain
 	notFound               HandlerFuncChain
@@ -99,8 +149,17 @@ func newRouter(logger ILogger) *Router {
 		UnescapePathValues:     true,
 		RedirectFixedPath:      false,
 		HandleMethodNotAllowed: true,
+		MaxMultipartMemory:     defaultMemory,
+		StreamKeepAlive:        defaultStreamKeepAlive,
+		StreamFlushInterval:    defaultStreamFlushInterval,
+		ErrorHandler:           DefaultErrorHandler,
+		RemoteIPHeaders:        append([]string{}, defaultRemoteIPHeaders...),
+		SchemeHeaders:          append([]string{}, defaultSchemeHeaders...),
+		HostHeaders:            append([]string{}, defaultHostHeaders...),
+		SecureJSONPrefix:       render.DefaultSecureJSONPrefix,
 		trees:                  map[string]nodelocation{},
 		pathHandlers:           map[string]pathHandler{},
+		html:                   &htmlEngine{},
 	}
 	router.pool = &sync.Pool{
 		New: func() interface{} {
@@ -114,20 +173,13 @@ func newRouter(logger ILogger) *Router {
 			}
 		},
 	}
-	router.usePool = &sync.Pool{
-		New: func() interface{} {
-			cpHandler := make(HandlerFuncChain, len(router.use)+1)
-			copy(cpHandler, router.use)
-			cpHandler[len(cpHandler)-1] = func(c Context) {
-				c.JSON(http.StatusNotFound, NewDefaultResponseBody(http.StatusNotFound, http.StatusText(http.StatusNotFound)))
-			}
-			return cpHandler
-		},
-	}
 	return router
 }
 
 var match, _ = regexp.Compile("(?P<match>= ?)")
+
+// routeParam is ルートのパス中の :name, *name
+var routeParam = regexp.MustCompile(`[:*]([a-zA-Z0-9]+)`)
 var prefixMatch, _ = regexp.Compile("(?P<match>~ ?)")
 
 func (r *Router) path(relativePath string) string {
@@ -151,19 +203,109 @@ func (r *Router) path(relativePath string) string {
 	return p
 }
 
+// Routes is 登録されたルートの一覧 (GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS の順)
+func (r *Router) Routes() []RouterInfo {
+	methods := make([]string, 0, len(r.routes))
+	for method := range r.routes {
+		methods = append(methods, method)
+	}
+	order := map[string]int{http.MethodGet: 1, http.MethodHead: 2, http.MethodPost: 3, http.MethodPut: 4, http.MethodPatch: 5, http.MethodDelete: 6, http.MethodOptions: 7}
+	sort.Slice(methods, func(i, j int) bool {
+		oi, oj := order[methods[i]], order[methods[j]]
+		if oi == 0 || oj == 0 {
+			if oi == oj {
+				return methods[i] < methods[j]
+			}
+			return oj == 0
+		}
+		return oi < oj
+	})
+	routes := make([]RouterInfo, 0)
+	for _, method := range methods {
+		routes = append(routes, r.routes[method]...)
+	}
+	return routes
+}
+
+// Reverse is ルートのパスのパラメータを params で順番に置き換えた URL を返す
+// 	name は /users/:id のようなパスか、ルートの handler の関数名 (パッケージ名を除いた名前でもよい)
+func (r *Router) Reverse(name string, params ...interface{}) (string, error) {
+	routePath := ""
+	if strings.HasPrefix(name, "/") {
+		routePath = name
+	} else {
+		for _, route := range r.Routes() {
+			if route.HandlerName == name || operationID(route.HandlerName) == name {
+				routePath = route.Path
+				break
+			}
+		}
+		if routePath == "" {
+			return "", fmt.Errorf("fwncs: route %q is not found", name)
+		}
+	}
+	routePath = strings.TrimPrefix(routePath, "= ")
+	routePath = strings.TrimPrefix(routePath, "~ ")
+	idxs := routeParam.FindAllStringIndex(routePath, -1)
+	if len(idxs) != len(params) {
+		return "", fmt.Errorf("fwncs: route %q requires %d params, got %d", routePath, len(idxs), len(params))
+	}
+	var b strings.Builder
+	last := 0
+	for i, idx := range idxs {
+		b.WriteString(routePath[last:idx[0]])
+		value := fmt.Sprint(params[i])
+		if routePath[idx[0]] == '*' {
+			// *name は / を含むのでセグメント毎にエスケープする
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			b.WriteString(strings.Join(segments, "/"))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+		last = idx[1]
+	}
+	b.WriteString(routePath[last:])
+	return b.String(), nil
+}
+
 func (r *Router) Handler(method, path string, h ...HandlerFunc) {
+	r.handle(method, path, "", h)
+}
+
+// handle is handlerName が空の場合は最後の handler の関数名をルート情報に記録する
+// 	最後の handler が Typed の場合は Req, Resp の型と Typed に渡した関数名を記録する
+// 	ルート情報には Group, With で設定した RouteOption の後に opts を適用する
+func (r *Router) handle(method, path, handlerName string, h HandlerFuncChain, opts ...RouteOption) {
 	path = r.path(path)
 	h = r.mergeHandlers(h)
 	info := r.routes[method]
 	if info == nil {
 		info = []RouterInfo{}
 	}
-	lastHandler := HandlerFuncChain(h).Last()
-	info = append(info, RouterInfo{
+	route := RouterInfo{
 		Method:      method,
 		Path:        path,
-		HandlerName: NameOfFunction(lastHandler),
-	})
+		HandlerName: handlerName,
+	}
+	if typed := lookupTypedHandler(h.Last()); typed != nil {
+		route.Request, route.Response = typed.Request, typed.Response
+		if route.HandlerName == "" {
+			route.HandlerName = NameOfFunction(typed.fn.Interface())
+		}
+	}
+	if route.HandlerName == "" {
+		route.HandlerName = NameOfFunction(h.Last())
+	}
+	for _, opt := range r.options {
+		opt(&route)
+	}
+	for _, opt := range opts {
+		opt(&route)
+	}
+	info = append(info, route)
 	r.routes[method] = info
 	ph, ok := r.pathHandlers[method]
 	if !ok {
@@ -218,14 +360,37 @@ func (r *Router) Group(path string, middleware ...HandlerFunc) *Router {
 	router := newRouter(r.logger)
 	router.group = r.path(path)
 	router.use = u
+	router.options = append([]RouteOption{}, r.options...)
 	router.routes = r.routes
 	router.pool = r.pool
 	router.trees = r.trees
 	router.pathHandlers = r.pathHandlers
+	router.html = r.html
 	router.maxParams = r.maxParams
 	return router
 }
 
+// With is 同じパスとミドルウェアで、登録するルートに opts を適用する Router を返す
+// 	router.With(fwncs.SecurityOption(issuer, "items:delete")).DELETE(...) のように使う
+func (r *Router) With(opts ...RouteOption) *Router {
+	router := r.Group("")
+	router.options = append(router.options, opts...)
+	return router
+}
+
+// Secure is Auth と Permission をミドルウェアに追加し、登録するルートに SecurityOption を記録する Router を返す
+// 	router.Secure(opt, "scope", "items:delete").DELETE(...) のように使う
+// 	scopes が空の場合は Permission を追加せず、Auth だけを記録する
+func (r *Router) Secure(opt AuthOption, permissionClaim string, scopes ...string) *Router {
+	middleware := []HandlerFunc{Auth(opt)}
+	if len(scopes) > 0 {
+		middleware = append(middleware, Permission(permissionClaim, scopes...))
+	}
+	router := r.Group("", middleware...)
+	router.options = append(router.options, SecurityOption(opt.Issuer, scopes...))
+	return router
+}
+
 func (r *Router) Any(path string, h ...HandlerFunc) *Router {
 	r.OPTIONS(path, h...)
 	r.HEAD(path, h...)
@@ -344,15 +509,12 @@ func (r *Router) run(s *http.Server) error {
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c := r.pool.Get().(*_context)
-	cpHandler := r.usePool.Get().(HandlerFuncChain)
 	c.reset(w, req)
 	c.logger = r.logger
-	c.handler = cpHandler
 
 	r.handleHTTPRequest(c)
 
 	r.pool.Put(c)
-	r.usePool.Put(cpHandler)
 }
 
 func (r *Router) ServeFiles(paths string, fs http.FileSystem) {
@@ -390,6 +552,7 @@ func (r *Router) handleHTTPRequest(c *_context) {
 		*c.params = *node.params
 		c.handler = ph.handler[node.index]
 		c.Next()
+		c.handleErrors()
 		c.w.WriteHeaderNow()
 		return
 	}
@@ -435,25 +598,12 @@ func serveError(c Context, code int, message string) {
 	if c.Writer().Written() {
 		return
 	}
+	if c.Writer().Status() == code && useProblemDetails(c) {
+		c.Problem(NewProblem(code, "").WithInstance(c.Request().URL.Path))
+		return
+	}
 	if c.Writer().Status() == code {
-		htmlText := `
-<!DOCTYPE html>
-<html lang="ja">
-<head>
-  <meta charset="UTF-8">
-  <meta http-equiv="X-UA-Compatible" content="IE=edge">
-  <meta name="viewport" content="width=device-width, initial-scale=1.0">
-  <title>{{.Message}}</title>
-</head>
-<body>
-  <p>{{.Message}}</p>
-</body>
-</html>
-		`
-
-		c.TemplateText(code, htmlText, map[string]string{
-			"Message": http.StatusText(code),
-		})
+		renderErrorPage(c, code, constant.HTML.String())
 		return
 	}
 	c.Writer().WriteHeaderNow()
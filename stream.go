@@ -0,0 +1,159 @@
+package fwncs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/render"
+)
+
+// syncResponseWriter is Stream 中に keep-alive を送る goroutine と handler の書き込みを排他する
+type syncResponseWriter struct {
+	ResponseWriter
+	mu sync.Mutex
+}
+
+func (w *syncResponseWriter) Write(buf []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(buf)
+}
+
+func (w *syncResponseWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *syncResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ResponseWriter.Flush()
+}
+
+func (w *syncResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *syncResponseWriter) keepAlive() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = w.ResponseWriter.WriteString(": keep-alive\n\n")
+	w.ResponseWriter.Flush()
+}
+
+// isEventStream is レスポンスの Content-Type が text/event-stream か
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(header.Get(constant.HeaderContentType), constant.EventStream.String())
+}
+
+func (c *_context) Stream(step func(w io.Writer) bool) bool {
+	done := c.GetContext().Done()
+	w := &syncResponseWriter{ResponseWriter: c.w}
+	c.w = w
+	var (
+		stop chan struct{}
+		wg   sync.WaitGroup
+	)
+	// keep-alive の goroutine が終わってから元の ResponseWriter に戻す
+	defer func() {
+		if stop != nil {
+			close(stop)
+		}
+		wg.Wait()
+		c.w = w.ResponseWriter
+	}()
+	keepAlive := func() {
+		interval := c.router.StreamKeepAlive
+		if stop != nil || interval <= 0 || !isEventStream(w.Header()) {
+			return
+		}
+		stop = make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					w.keepAlive()
+				case <-stop:
+					return
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	if isEventStream(w.Header()) {
+		header := w.Header()
+		header.Set(constant.HeaderCacheControl, "no-cache")
+		header.Set(constant.HeaderConnection, "keep-alive")
+		// nginx のバッファリングを無効にする
+		header.Set("X-Accel-Buffering", "no")
+		w.Flush()
+		keepAlive()
+	}
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(w)
+			w.Flush()
+			if !keepOpen {
+				return false
+			}
+			keepAlive()
+		}
+	}
+}
+
+func (c *_context) SSEvent(name string, data interface{}) {
+	c.Render(-1, render.SSEvent{Event: name, Data: data})
+}
+
+func (c *_context) SSEventWithID(id, name string, data interface{}) {
+	c.Render(-1, render.SSEvent{ID: id, Event: name, Data: data})
+}
+
+func (c *_context) LastEventID() string {
+	return c.Header().Get(constant.HeaderLastEventID)
+}
+
+func (c *_context) NDJSON(status int, items interface{}) {
+	c.renderStream(status, render.NDJSON{Context: c.GetContext(), Items: items, FlushInterval: c.router.StreamFlushInterval})
+}
+
+func (c *_context) JSONArray(status int, items interface{}) {
+	c.renderStream(status, render.JSONArray{Context: c.GetContext(), Items: items, FlushInterval: c.router.StreamFlushInterval})
+}
+
+// renderStream is c.Render と異なりエラーで panic しない
+func (c *_context) renderStream(status int, r render.Render) {
+	c.SetStatus(status)
+	err := r.Render(c.Writer())
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		c.Skip()
+	case c.Writer().Size() <= 0:
+		c.Writer().Header().Del(constant.HeaderContentType)
+		c.Writer().Header().Del(constant.HeaderContentDisposition)
+		c.AbortWithError(http.StatusInternalServerError, err)
+	default:
+		c.Logger().Error(err)
+		c.Skip()
+	}
+}
@@ -0,0 +1,237 @@
+package fwncs_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSEvent(t *testing.T) {
+	router := fwncs.New()
+	router.GET("/events", func(c fwncs.Context) {
+		id := 0
+		if last := c.LastEventID(); last == "1" {
+			id = 2
+		}
+		c.Stream(func(w io.Writer) bool {
+			id++
+			c.SSEventWithID(strconv.Itoa(id), "message", map[string]int{"id": id})
+			return id < 3
+		})
+	})
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set(constant.HeaderAccept, constant.EventStream.String())
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, constant.EventStream.String(), rw.Header().Get(constant.HeaderContentType))
+	assert.Equal(t, "no-cache", rw.Header().Get(constant.HeaderCacheControl))
+	assert.True(t, rw.Flushed)
+	assert.Equal(t, "id: 1\nevent: message\ndata: {\"id\":1}\n\n"+
+		"id: 2\nevent: message\ndata: {\"id\":2}\n\n"+
+		"id: 3\nevent: message\ndata: {\"id\":3}\n\n", rw.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set(constant.HeaderAccept, constant.EventStream.String())
+	req.Header.Set(constant.HeaderLastEventID, "1")
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, "id: 3\nevent: message\ndata: {\"id\":3}\n\n", rw.Body.String())
+}
+
+func TestStreamKeepAliveAndDisconnect(t *testing.T) {
+	router := fwncs.New()
+	router.StreamKeepAlive = 50 * time.Millisecond
+	messages := make(chan string)
+	closed := make(chan bool, 1)
+	router.GET("/events", func(c fwncs.Context) {
+		c.SetHeader(constant.HeaderContentType, constant.EventStream.String())
+		closed <- c.Stream(func(w io.Writer) bool {
+			select {
+			case msg := <-messages:
+				c.SSEvent("", msg)
+			case <-c.GetContext().Done():
+			}
+			return true
+		})
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		cancel()
+		return
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, ": keep-alive\n", line)
+	_, _ = reader.ReadString('\n')
+
+	messages <- "hello"
+	for {
+		line, err = reader.ReadString('\n')
+		if err != nil || strings.HasPrefix(line, "data:") {
+			break
+		}
+	}
+	assert.Equal(t, "data: hello\n", line)
+
+	cancel()
+	select {
+	case disconnected := <-closed:
+		assert.True(t, disconnected)
+	case <-time.After(3 * time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+}
+
+func TestStreamKeepAliveOnlyForEventStream(t *testing.T) {
+	router := fwncs.New()
+	router.StreamKeepAlive = 10 * time.Millisecond
+	router.GET("/text", func(c fwncs.Context) {
+		c.SetHeader(constant.HeaderContentType, constant.Plain.String())
+		count := 0
+		c.Stream(func(w io.Writer) bool {
+			count++
+			_, _ = io.WriteString(w, "line\n")
+			time.Sleep(30 * time.Millisecond)
+			return count < 2
+		})
+	})
+	router.GET("/events", func(c fwncs.Context) {
+		count := 0
+		c.Stream(func(w io.Writer) bool {
+			count++
+			c.SSEvent("", count)
+			time.Sleep(30 * time.Millisecond)
+			return count < 2
+		})
+		// Stream が返った後は keep-alive が書き込まれない
+		_, _ = c.Writer().WriteString("end\n")
+		time.Sleep(30 * time.Millisecond)
+	})
+	serve := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(constant.HeaderAccept, constant.EventStream.String())
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw.Body.String()
+	}
+	assert.Equal(t, "line\nline\n", serve("/text"))
+	body := serve("/events")
+	assert.True(t, strings.HasPrefix(body, "data: 1\n\n"))
+	assert.Contains(t, body, ": keep-alive\n\n")
+	assert.Contains(t, body, "data: 2\n\n")
+	assert.True(t, strings.HasSuffix(body, "\n\nend\n"))
+}
+
+func TestJSONStream(t *testing.T) {
+	type row struct {
+		ID int `json:"id"`
+	}
+	router := fwncs.New()
+	router.GET("/ndjson", func(c fwncs.Context) {
+		rows := make(chan row)
+		go func() {
+			defer close(rows)
+			for i := 1; i <= 3; i++ {
+				rows <- row{ID: i}
+			}
+		}()
+		c.NDJSON(http.StatusOK, rows)
+	})
+	router.GET("/array", func(c fwncs.Context) {
+		i := 0
+		c.JSONArray(http.StatusOK, func() (interface{}, bool, error) {
+			i++
+			return row{ID: i}, i <= 2, nil
+		})
+	})
+	router.GET("/empty", func(c fwncs.Context) {
+		c.JSONArray(http.StatusOK, make(chan row))
+	})
+	router.GET("/invalid", func(c fwncs.Context) {
+		c.NDJSON(http.StatusOK, []row{})
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+	rw := serve("/ndjson")
+	assert.Equal(t, constant.NDJSON.String(), rw.Header().Get(constant.HeaderContentType))
+	assert.True(t, rw.Flushed)
+	assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", rw.Body.String())
+
+	rw = serve("/array")
+	assert.Equal(t, constant.JSON.String(), rw.Header().Get(constant.HeaderContentType))
+	assert.Equal(t, `[{"id":1},{"id":2}]`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/empty", nil).WithContext(ctx))
+	assert.Equal(t, "[", rw.Body.String())
+
+	rw = serve("/invalid")
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	assert.NotEqual(t, constant.NDJSON.String(), rw.Header().Get(constant.HeaderContentType))
+}
+
+func TestJSONStreamDisconnect(t *testing.T) {
+	router := fwncs.New()
+	router.StreamFlushInterval = 20 * time.Millisecond
+	stopped := make(chan struct{})
+	router.GET("/ndjson", func(c fwncs.Context) {
+		rows := make(chan int)
+		go func() {
+			defer close(stopped)
+			for i := 0; ; i++ {
+				select {
+				case rows <- i:
+				case <-c.GetContext().Done():
+					return
+				}
+				if i > 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		}()
+		c.NDJSON(http.StatusOK, rows)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/ndjson", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		cancel()
+		return
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "0\n", line)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+}
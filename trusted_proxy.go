@@ -0,0 +1,163 @@
+package fwncs
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+// Forwarded (RFC 7239) は X-Forwarded-For だけを追加するプロキシがクライアントの値をそのまま転送するため、
+// デフォルトでは参照しない (Router.TrustForwardedHeader で有効にする)
+var (
+	defaultRemoteIPHeaders = []string{constant.HeaderXForwardedFor, constant.HeaderXRealIP}
+	defaultSchemeHeaders   = []string{constant.HeaderXForwardedProto, constant.HeaderXForwardedProtocol, constant.HeaderXForwardedSsl, constant.HeaderXUrlScheme}
+	defaultHostHeaders     = []string{constant.HeaderXForwardedHost}
+)
+
+// SetTrustedProxies is 転送ヘッダーを信頼するプロキシの IP アドレスまたは CIDR を設定する
+// 	接続元が信頼するプロキシでない場合、ClientIP と Scheme は転送ヘッダーを無視する
+// 	何も設定しない場合 (デフォルト) は全ての転送ヘッダーを無視する
+func (r *Router) SetTrustedProxies(proxies ...string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		cidr, err := parseTrustedProxy(proxy)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	r.trustedProxies = cidrs
+	return nil
+}
+
+// TrustForwardedHeader is 信頼するプロキシの Forwarded ヘッダーを RemoteIPHeaders, SchemeHeaders, HostHeaders の先頭に追加する
+// 	信頼するプロキシが全て Forwarded に要素を追加する (またはクライアントの値を削除する) 場合だけ使う
+func (r *Router) TrustForwardedHeader() {
+	r.RemoteIPHeaders = prependHeader(r.RemoteIPHeaders, constant.HeaderForwarded)
+	r.SchemeHeaders = prependHeader(r.SchemeHeaders, constant.HeaderForwarded)
+	r.HostHeaders = prependHeader(r.HostHeaders, constant.HeaderForwarded)
+}
+
+func prependHeader(headers []string, name string) []string {
+	for _, header := range headers {
+		if http.CanonicalHeaderKey(header) == name {
+			return headers
+		}
+	}
+	return append([]string{name}, headers...)
+}
+
+func parseTrustedProxy(proxy string) (*net.IPNet, error) {
+	proxy = strings.TrimSpace(proxy)
+	if strings.Contains(proxy, "/") {
+		_, cidr, err := net.ParseCIDR(proxy)
+		return cidr, err
+	}
+	ip := net.ParseIP(proxy)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+	}
+	bits := net.IPv6len * 8
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip, bits = ipv4, net.IPv4len*8
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func (r *Router) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range r.trustedProxies {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP is RemoteAddr の IP アドレス
+func remoteIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(req.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(req.RemoteAddr)
+	}
+	return net.ParseIP(host)
+}
+
+// forwardedClientIP is 転送ヘッダーの IP アドレスを右 (接続元に近い方) から辿り、最初の信頼しないアドレスを返す
+// 	全て信頼するプロキシの場合は最も左のアドレスを返す
+// 	不正な値を含む場合は ok が false になる
+func (r *Router) forwardedClientIP(values []string) (ip string, ok bool) {
+	i, ok := r.clientIndex(values)
+	if !ok {
+		return "", false
+	}
+	return net.ParseIP(values[i]).String(), true
+}
+
+// clientIndex is forwardedClientIP が返すアドレスの位置
+func (r *Router) clientIndex(values []string) (int, bool) {
+	for i := len(values) - 1; i >= 0; i-- {
+		parsed := net.ParseIP(values[i])
+		if parsed == nil {
+			return 0, false
+		}
+		if i == 0 || !r.isTrustedProxy(parsed) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// forwardedValue is 信頼するプロキシの転送ヘッダーから値を取得する
+// 	Forwarded は ClientIP と同じ要素の値を返す
+// 	それ以外のヘッダーはプロキシ毎に右に追加されるので、右から信頼するプロキシの数だけ飛ばした値を返す
+// 	(左側はクライアントが送った値かもしれないので使わない)
+func (r *Router) forwardedValue(header http.Header, name string, value func(e ForwardedElement) string) string {
+	if http.CanonicalHeaderKey(name) == constant.HeaderForwarded {
+		if hop, ok := r.forwardedHop(header); ok {
+			return value(hop)
+		}
+		return ""
+	}
+	values := headerValues(header, name)
+	if len(values) == 0 {
+		return ""
+	}
+	i := len(values) - 1 - r.trustedHops(header)
+	if i < 0 {
+		// 全てのプロキシが値を追加するとは限らない (上書きする場合もある)
+		i = 0
+	}
+	return values[i]
+}
+
+// trustedHops is X-Forwarded-For を右から辿り、最初の信頼しないアドレスまでにある信頼するプロキシの数
+func (r *Router) trustedHops(header http.Header) int {
+	values := headerValues(header, constant.HeaderXForwardedFor)
+	hops := 0
+	for i := len(values) - 1; i > 0; i-- {
+		if !r.isTrustedProxy(net.ParseIP(values[i])) {
+			break
+		}
+		hops++
+	}
+	return hops
+}
+
+// headerValues is カンマ区切りのヘッダーを複数行も含めて分割する
+func headerValues(header http.Header, name string) []string {
+	values := make([]string, 0)
+	for _, line := range header.Values(name) {
+		for _, value := range strings.Split(line, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,169 @@
+package fwncs
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/n-creativesystem/go-fwncs/binding"
+)
+
+var (
+	contextType = reflect.TypeOf((*Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typedHandlers is TypedHandler.HandlerFunc が返した HandlerFunc の TypedHandler
+// 	HandlerFunc は比較できないためクロージャのアドレスをキーにし、アドレスが再利用されないように HandlerFunc も保持する
+var typedHandlers sync.Map
+
+type typedHandlerFunc struct {
+	handler HandlerFunc
+	typed   *TypedHandler
+}
+
+func handlerFuncID(h HandlerFunc) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&h))
+}
+
+// lookupTypedHandler is h が Typed, TypedHandler.HandlerFunc で作成した HandlerFunc の場合は TypedHandler を返す
+func lookupTypedHandler(h HandlerFunc) *TypedHandler {
+	if h == nil {
+		return nil
+	}
+	v, ok := typedHandlers.Load(handlerFuncID(h))
+	if !ok {
+		return nil
+	}
+	return v.(typedHandlerFunc).typed
+}
+
+// StatusCoder is Typed の Resp が実装すると、200 の代わりにそのステータスで返却する
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// TypedHandler is func(Context, *Req) (*Resp, error) の型情報
+type TypedHandler struct {
+	fn       reflect.Value
+	Request  reflect.Type
+	Response reflect.Type
+}
+
+// NewTypedHandler is fn が func(Context, *Req) (*Resp, error) でない場合は error を返す
+// 	Req, Resp は構造体でなければならない
+func NewTypedHandler(fn interface{}) (*TypedHandler, error) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 2 {
+		return nil, fmt.Errorf("fwncs: typed handler must be func(Context, *Req) (*Resp, error), got %s", t)
+	}
+	if t.In(0) != contextType || t.Out(1) != errorType {
+		return nil, fmt.Errorf("fwncs: typed handler must be func(Context, *Req) (*Resp, error), got %s", t)
+	}
+	req, resp := t.In(1), t.Out(0)
+	if req.Kind() != reflect.Ptr || req.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fwncs: typed handler request must be a pointer to a struct, got %s", req)
+	}
+	if resp.Kind() != reflect.Ptr || resp.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fwncs: typed handler response must be a pointer to a struct, got %s", resp)
+	}
+	return &TypedHandler{fn: v, Request: req.Elem(), Response: resp.Elem()}, nil
+}
+
+// Typed is func(Context, *Req) (*Resp, error) を HandlerFunc に変換する
+// 	Req は path, query, header タグのフィールドを URL パラメータ、クエリ、ヘッダーから、
+// 	それ以外を Content-Type に応じた binding で request body から読み込んで検証する
+// 	Resp は Negotiation.Data として Accept に応じたフォーマットで返却し、nil の場合は 204 になる
+// 	fn の型が正しくない場合は panic する
+func Typed(fn interface{}) HandlerFunc {
+	h, err := NewTypedHandler(fn)
+	if err != nil {
+		panic(err)
+	}
+	return h.HandlerFunc()
+}
+
+// HandlerFunc is Req を読み込んで fn を呼び出し、Resp を返却する HandlerFunc を返す
+// 	GET などで最後の handler として登録すると、RouterInfo に Req, Resp の型と fn の関数名を記録する
+func (h *TypedHandler) HandlerFunc() HandlerFunc {
+	handler := func(c Context) {
+		req := reflect.New(h.Request)
+		if err := bindTyped(c, req.Interface()); err != nil {
+			c.Error(bindError(err))
+			c.Skip()
+			return
+		}
+		out := h.fn.Call([]reflect.Value{reflect.ValueOf(c), req})
+		if err, _ := out[1].Interface().(error); err != nil {
+			c.Error(err)
+			c.Skip()
+			return
+		}
+		if c.Writer().Written() {
+			return
+		}
+		if out[0].IsNil() {
+			c.Writer().WriteHeader(http.StatusNoContent)
+			c.Writer().WriteHeaderNow()
+			return
+		}
+		resp := out[0].Interface()
+		status := http.StatusOK
+		if sc, ok := resp.(StatusCoder); ok && sc.StatusCode() > 0 {
+			status = sc.StatusCode()
+		}
+		c.Negotiate(status, Negotiation{Data: resp})
+	}
+	typedHandlers.Store(handlerFuncID(handler), typedHandlerFunc{handler: handler, typed: h})
+	return handler
+}
+
+// bindTyped is path, query, header を設定した後に request body を読み込んで検証する
+func bindTyped(c Context, obj interface{}) error {
+	if err := bindParams(c, obj, binding.MapValues); err != nil {
+		return err
+	}
+	if !hasBody(c.Request()) {
+		return binding.Validate(obj)
+	}
+	// binding は読み込んだ後に検証するので、required の path などは先に設定しておく
+	if err := c.Bind(obj); err != nil {
+		return err
+	}
+	// JSON などのフィールド名は大文字小文字を区別しないため、body で path の ID などを変更できないように設定し直す
+	if err := bindParams(c, obj, binding.OverwriteValues); err != nil {
+		return err
+	}
+	return binding.Validate(obj)
+}
+
+// bindParams is path, query, header タグのフィールドを mapValues で設定する
+func bindParams(c Context, obj interface{}, mapValues func(obj interface{}, values map[string][]string, tag string) error) error {
+	params := map[string][]string{}
+	for _, p := range c.Params() {
+		params[p.Key] = append(params[p.Key], p.Value)
+	}
+	if err := mapValues(obj, params, "path"); err != nil {
+		return err
+	}
+	if err := mapValues(obj, c.Request().URL.Query(), "query"); err != nil {
+		return err
+	}
+	return mapValues(obj, c.Header(), "header")
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// HandleTyped is Typed で変換した handler を middleware の後に登録する
+// 	RouterInfo の Request, Response に Req, Resp の型を、HandlerName に fn の関数名を記録する
+// 	(GET などに Typed を渡した場合も同じ情報を記録する)
+// 	fn の型が正しくない場合は panic する
+func (r *Router) HandleTyped(method, path string, fn interface{}, middleware ...HandlerFunc) {
+	handlers := append(HandlerFuncChain{}, middleware...)
+	r.handle(method, path, "", append(handlers, Typed(fn)))
+}
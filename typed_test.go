@@ -0,0 +1,187 @@
+package fwncs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+type updateUserRequest struct {
+	ID      int       `path:"id" json:"-"`
+	Verbose bool      `query:"verbose" json:"-"`
+	Limit   int       `query:"limit,default=10" json:"-"`
+	Tags    []string  `query:"tag" json:"-"`
+	Since   time.Time `query:"since" time_format:"2006-01-02" json:"-"`
+	Token   string    `header:"X-Token" json:"-" binding:"required"`
+	Name    string    `json:"name" binding:"required"`
+}
+
+type userResponse struct {
+	ID      int      `json:"id" xml:"id"`
+	Name    string   `json:"name" xml:"name"`
+	Limit   int      `json:"limit" xml:"limit"`
+	Tags    []string `json:"tags" xml:"tags"`
+	Since   string   `json:"since" xml:"since"`
+	created bool
+}
+
+func (r *userResponse) StatusCode() int {
+	if r.created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+func updateUser(c fwncs.Context, req *updateUserRequest) (*userResponse, error) {
+	if req.ID == 0 {
+		return nil, fwncs.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+	return &userResponse{
+		ID:      req.ID,
+		Name:    req.Name,
+		Limit:   req.Limit,
+		Tags:    req.Tags,
+		Since:   req.Since.Format("2006-01-02"),
+		created: req.Verbose,
+	}, nil
+}
+
+type deleteUserRequest struct {
+	ID int `path:"id" binding:"min=1"`
+}
+
+type emptyResponse struct{}
+
+type renameItemRequest struct {
+	ID    int    `path:"id"`
+	Limit int    `query:"limit"`
+	Name  string `binding:"required"`
+}
+
+func renameItem(c fwncs.Context, req *renameItemRequest) (*renameItemRequest, error) {
+	return req, nil
+}
+
+func deleteUser(c fwncs.Context, req *deleteUserRequest) (*emptyResponse, error) {
+	return nil, nil
+}
+
+func TestTypedHandler(t *testing.T) {
+	router := fwncs.New()
+	router.HandleTyped(http.MethodPut, "/users/:id", updateUser)
+	router.DELETE("/users/:id", fwncs.Typed(deleteUser))
+	router.HandleTyped(http.MethodPut, "/items/:id", renameItem)
+	serve := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body == "" {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+	jsonHeader := map[string]string{constant.HeaderContentType: constant.JSON.String(), "X-Token": "secret"}
+	tt := tests.TestFrames{
+		{Name: "bind path, query, header and body", Fn: func(t *testing.T) {
+			rw := serve(http.MethodPut, "/users/1?tag=a&tag=b&since=2021-04-01", `{"name":"alice"}`, jsonHeader)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.JSONEq(t, `{"id":1,"name":"alice","limit":10,"tags":["a","b"],"since":"2021-04-01"}`, rw.Body.String())
+
+			rw = serve(http.MethodPut, "/users/1?verbose=true&limit=5", `{"name":"alice"}`, jsonHeader)
+			assert.Equal(t, http.StatusCreated, rw.Code)
+			assert.Contains(t, rw.Body.String(), `"limit":5`)
+		}},
+		{Name: "negotiate response", Fn: func(t *testing.T) {
+			header := map[string]string{constant.HeaderContentType: constant.JSON.String(), "X-Token": "secret", constant.HeaderAccept: "application/xml"}
+			rw := serve(http.MethodPut, "/users/1", `{"name":"alice"}`, header)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Contains(t, rw.Body.String(), "<name>alice</name>")
+		}},
+		{Name: "invalid request", Fn: func(t *testing.T) {
+			rw := serve(http.MethodPut, "/users/x", `{"name":"alice"}`, jsonHeader)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Contains(t, rw.Body.String(), "id")
+
+			rw = serve(http.MethodPut, "/users/1", `{"name":`, jsonHeader)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+			rw = serve(http.MethodPut, "/users/1", `{}`, map[string]string{constant.HeaderContentType: constant.JSON.String()})
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			var body struct {
+				Details []map[string]string `json:"details"`
+			}
+			assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+			assert.Len(t, body.Details, 2)
+
+			rw = serve(http.MethodDelete, "/users/0", "", nil)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+		}},
+		{Name: "path and query win over body", Fn: func(t *testing.T) {
+			rw := serve(http.MethodPut, "/items/1", `{"id":999,"limit":7,"name":"box"}`, jsonHeader)
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.JSONEq(t, `{"ID":1,"Limit":0,"Name":"box"}`, rw.Body.String())
+
+			rw = serve(http.MethodPut, "/items/1?limit=3", `{"ID":999,"Limit":7,"Name":"box"}`, jsonHeader)
+			assert.JSONEq(t, `{"ID":1,"Limit":3,"Name":"box"}`, rw.Body.String())
+		}},
+		{Name: "handler error and no content", Fn: func(t *testing.T) {
+			rw := serve(http.MethodPut, "/users/0", `{"name":"alice"}`, jsonHeader)
+			assert.Equal(t, http.StatusNotFound, rw.Code)
+
+			rw = serve(http.MethodDelete, "/users/1", "", nil)
+			assert.Equal(t, http.StatusNoContent, rw.Code)
+			assert.Empty(t, rw.Body.String())
+		}},
+		{Name: "route type information", Fn: func(t *testing.T) {
+			var info *fwncs.RouterInfo
+			for _, route := range router.Routes() {
+				if route.Method == http.MethodPut && route.Path == "/users/:id" {
+					route := route
+					info = &route
+				}
+			}
+			if assert.NotNil(t, info) {
+				assert.Equal(t, "/users/:id", info.Path)
+				assert.Equal(t, reflect.TypeOf(updateUserRequest{}), info.Request)
+				assert.Equal(t, reflect.TypeOf(userResponse{}), info.Response)
+				assert.Contains(t, info.HandlerName, "updateUser")
+			}
+			// GET などに Typed を渡した場合も型を記録する
+			var deleted *fwncs.RouterInfo
+			for _, route := range router.Routes() {
+				if route.Method == http.MethodDelete {
+					route := route
+					deleted = &route
+				}
+			}
+			if assert.NotNil(t, deleted) {
+				assert.Equal(t, reflect.TypeOf(deleteUserRequest{}), deleted.Request)
+				assert.Equal(t, reflect.TypeOf(emptyResponse{}), deleted.Response)
+				assert.Contains(t, deleted.HandlerName, "deleteUser")
+			}
+			plain := fwncs.New()
+			plain.GET("/", func(c fwncs.Context) {})
+			assert.Nil(t, plain.Routes()[0].Request)
+			assert.Nil(t, plain.Routes()[0].Response)
+			assert.Panics(t, func() {
+				fwncs.Typed(func(c fwncs.Context) error { return nil })
+			})
+			assert.Panics(t, func() {
+				fwncs.New().HandleTyped(http.MethodGet, "/", func(c fwncs.Context) {})
+			})
+		}},
+	}
+	tt.Run(t)
+}
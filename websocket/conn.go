@@ -0,0 +1,524 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types (RFC 6455 11.8)
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// Close codes (RFC 6455 7.4.1, 1012 以降は IANA の WebSocket Close Code Number Registry)
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+	CloseServiceRestart          = 1012
+	CloseTryAgainLater           = 1013
+	CloseBadGateway              = 1014
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	maxControlFramePayloadSize = 125
+)
+
+var (
+	ErrReadLimit  = errors.New("websocket: read limit exceeded")
+	ErrCloseSent  = errors.New("websocket: close sent")
+	errBadMessage = errors.New("websocket: bad message type")
+)
+
+// CloseError is 相手から close フレームを受信した際に ReadMessage が返すエラー
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// IsCloseError is err が codes のいずれかの CloseError かを返す
+func IsCloseError(err error, codes ...int) bool {
+	var e *CloseError
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is WebSocket のコネクション
+// 	ReadMessage と WriteMessage はそれぞれ 1 つの goroutine からのみ呼び出すこと
+// 	WriteControl, Close は他の goroutine から呼び出しても安全
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+	compress    bool
+	readLimit   int64
+
+	writeMu   sync.Mutex
+	writeBuf  []byte
+	closeSent bool
+
+	pingHandler  func(data string) error
+	pongHandler  func(data string) error
+	closeHandler func(code int, text string) error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, writeBufferSize int) *Conn {
+	c := &Conn{
+		conn:     conn,
+		br:       br,
+		writeBuf: make([]byte, 0, writeBufferSize),
+	}
+	c.SetPingHandler(nil)
+	c.SetPongHandler(nil)
+	c.SetCloseHandler(nil)
+	return c
+}
+
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit is 受信するメッセージの上限バイト数 (0 以下は無制限)
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPingHandler is ping 受信時の処理 (nil の場合は pong を返す)
+func (c *Conn) SetPingHandler(h func(data string) error) {
+	if h == nil {
+		h = func(data string) error {
+			err := c.WriteControl(PongMessage, []byte(data), time.Now().Add(time.Second))
+			if err == ErrCloseSent {
+				return nil
+			}
+			return err
+		}
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler is pong 受信時の処理 (nil の場合は何もしない)
+func (c *Conn) SetPongHandler(h func(data string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pongHandler = h
+}
+
+// SetCloseHandler is close 受信時の処理 (nil の場合は同じコードで close を返す)
+func (c *Conn) SetCloseHandler(h func(code int, text string) error) {
+	if h == nil {
+		h = func(code int, text string) error {
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+			err := c.WriteControl(CloseMessage, FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+			if err == ErrCloseSent {
+				return nil
+			}
+			return err
+		}
+	}
+	c.closeHandler = h
+}
+
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	opcode int
+	length int64
+	mask   [4]byte
+}
+
+func (c *Conn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	p := make([]byte, 2)
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return h, err
+	}
+	h.fin = p[0]&finalBit != 0
+	h.rsv1 = p[0]&rsv1Bit != 0
+	h.opcode = int(p[0] & 0xf)
+	if p[0]&(rsv2Bit|rsv3Bit) != 0 || (h.rsv1 && !c.compress) {
+		return h, c.protocolError("unexpected reserved bits")
+	}
+	if p[1]&maskBit == 0 {
+		return h, c.protocolError("client frame is not masked")
+	}
+	switch length := int64(p[1] & 0x7f); length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.br, ext); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.br, ext); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(ext))
+		if h.length < 0 {
+			return h, c.protocolError("invalid payload length")
+		}
+	default:
+		h.length = length
+	}
+	if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+		return h, err
+	}
+	switch h.opcode {
+	case CloseMessage, PingMessage, PongMessage:
+		if h.length > maxControlFramePayloadSize {
+			return h, c.protocolError("control frame length > 125")
+		}
+		if !h.fin {
+			return h, c.protocolError("control frame not final")
+		}
+		if h.rsv1 {
+			return h, c.protocolError("control frame compressed")
+		}
+	case continuationFrame, TextMessage, BinaryMessage:
+	default:
+		return h, c.protocolError("unknown opcode " + strconv.Itoa(h.opcode))
+	}
+	return h, nil
+}
+
+// readPayload is ペイロードをマスク解除して dst に追記する
+func (c *Conn) readPayload(h frameHeader, dst *bytes.Buffer) error {
+	start := dst.Len()
+	if _, err := io.CopyN(dst, c.br, h.length); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	payload := dst.Bytes()[start:]
+	for i := range payload {
+		payload[i] ^= h.mask[i%4]
+	}
+	return nil
+}
+
+// ReadMessage is 次のデータメッセージを読み込む
+// 	ping, pong, close は各ハンドラーで処理し、close を受信した場合は *CloseError を返す
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	var (
+		buf        bytes.Buffer
+		compressed bool
+	)
+	messageType = continuationFrame
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch h.opcode {
+		case PingMessage, PongMessage, CloseMessage:
+			var payload bytes.Buffer
+			if err := c.readPayload(h, &payload); err != nil {
+				return 0, nil, err
+			}
+			if err := c.handleControl(h.opcode, payload.Bytes()); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case continuationFrame:
+			if messageType == continuationFrame {
+				return 0, nil, c.protocolError("continuation frame without start")
+			}
+			if h.rsv1 {
+				return 0, nil, c.protocolError("continuation frame compressed")
+			}
+		default:
+			if messageType != continuationFrame {
+				return 0, nil, c.protocolError("message started before previous message completed")
+			}
+			messageType = h.opcode
+			compressed = h.rsv1
+		}
+		if c.readLimit > 0 && int64(buf.Len())+h.length > c.readLimit {
+			c.closeWithError(CloseMessageTooBig, "")
+			return 0, nil, ErrReadLimit
+		}
+		if err := c.readPayload(h, &buf); err != nil {
+			return 0, nil, err
+		}
+		if h.fin {
+			break
+		}
+	}
+	p = buf.Bytes()
+	if compressed {
+		if p, err = c.decompress(p); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(p) {
+		c.closeWithError(CloseInvalidFramePayloadData, "invalid utf8 payload")
+		return 0, nil, errors.New("websocket: invalid utf8 payload")
+	}
+	return messageType, p, nil
+}
+
+func (c *Conn) handleControl(opcode int, payload []byte) error {
+	switch opcode {
+	case PingMessage:
+		return c.pingHandler(string(payload))
+	case PongMessage:
+		return c.pongHandler(string(payload))
+	}
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		return c.protocolError("invalid close payload")
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.protocolError("invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return c.protocolError("invalid utf8 payload in close frame")
+		}
+	}
+	if err := c.closeHandler(closeErr.Code, closeErr.Text); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func (c *Conn) decompress(p []byte) ([]byte, error) {
+	// RFC 7692 7.2.2 取り除かれた末尾の空ブロックを補う
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff})))
+	defer r.Close()
+	var reader io.Reader = r
+	if c.readLimit > 0 {
+		reader = io.LimitReader(r, c.readLimit+1)
+	}
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.readLimit > 0 && int64(len(buf)) > c.readLimit {
+		c.closeWithError(CloseMessageTooBig, "")
+		return nil, ErrReadLimit
+	}
+	return buf, nil
+}
+
+func (c *Conn) protocolError(message string) error {
+	c.closeWithError(CloseProtocolError, message)
+	return errors.New("websocket: " + message)
+}
+
+func (c *Conn) closeWithError(code int, text string) {
+	_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, text), time.Now().Add(time.Second))
+}
+
+// WriteMessage is データメッセージを 1 フレームで送信する
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		if isControl(messageType) {
+			return c.WriteControl(messageType, data, time.Time{})
+		}
+		return errBadMessage
+	}
+	rsv1 := false
+	if c.compress {
+		compressed, err := compress(data)
+		if err != nil {
+			return err
+		}
+		data, rsv1 = compressed, true
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	return c.writeFrame(messageType, rsv1, data)
+}
+
+// WriteJSON is v を JSON にエンコードしてテキストメッセージとして送信する
+func (c *Conn) WriteJSON(v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, buf)
+}
+
+// ReadJSON is 次のメッセージを JSON として v に読み込む
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, p, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(p, v)
+}
+
+// WriteControl is ping, pong, close を送信する
+// 	deadline がゼロ値の場合はタイムアウトしない
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if !isControl(messageType) {
+		return errBadMessage
+	}
+	if len(data) > maxControlFramePayloadSize {
+		return errors.New("websocket: invalid control frame")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if !deadline.IsZero() {
+		_ = c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+	if messageType == CloseMessage {
+		c.closeSent = true
+	}
+	return c.writeFrame(messageType, false, data)
+}
+
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data, time.Now().Add(10*time.Second))
+}
+
+func (c *Conn) writeFrame(opcode int, rsv1 bool, data []byte) error {
+	b0 := byte(opcode) | finalBit
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	buf := append(c.writeBuf[:0], b0)
+	switch length := len(data); {
+	case length <= 125:
+		buf = append(buf, byte(length))
+	case length <= 0xffff:
+		buf = append(buf, 126, byte(length>>8), byte(length))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(length))
+		buf = append(buf, 127)
+		buf = append(buf, ext...)
+	}
+	buf = append(buf, data...)
+	_, err := c.conn.Write(buf)
+	if cap(buf) <= cap(c.writeBuf)*2 {
+		c.writeBuf = buf
+	}
+	return err
+}
+
+// Close is close フレーム (1000) を送信してコネクションを閉じる
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+// CloseWithCode is code, text の close フレームを送信してコネクションを閉じる
+func (c *Conn) CloseWithCode(code int, text string) error {
+	_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, text), time.Now().Add(time.Second))
+	return c.conn.Close()
+}
+
+// FormatCloseMessage is close フレームのペイロードを作る
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+func isControl(messageType int) bool {
+	return messageType == CloseMessage || messageType == PingMessage || messageType == PongMessage
+}
+
+func compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := flate.NewWriter(buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	// RFC 7692 7.2.1 末尾の 0x00 0x00 0xff 0xff を取り除く
+	p := buf.Bytes()
+	if !bytes.HasSuffix(p, []byte{0x00, 0x00, 0xff, 0xff}) {
+		return nil, fmt.Errorf("websocket: unexpected deflate trailer")
+	}
+	return p[:len(p)-4], nil
+}
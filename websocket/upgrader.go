@@ -0,0 +1,240 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs/constant"
+)
+
+const (
+	headerSecWebSocketKey        = "Sec-WebSocket-Key"
+	headerSecWebSocketVersion    = "Sec-WebSocket-Version"
+	headerSecWebSocketAccept     = "Sec-WebSocket-Accept"
+	headerSecWebSocketProtocol   = "Sec-WebSocket-Protocol"
+	headerSecWebSocketExtensions = "Sec-WebSocket-Extensions"
+
+	// RFC 6455 1.3
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	extensionDeflate = "permessage-deflate"
+)
+
+// DefaultReadLimit is Upgrader.ReadLimit が 0 の場合の受信するメッセージの上限バイト数
+const DefaultReadLimit = 32 << 10
+
+// HandshakeError is opening handshake の失敗
+// 	Status はクライアントへ返却する HTTP ステータス
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// Upgrader is HTTP のリクエストを WebSocket にアップグレードする設定
+type Upgrader struct {
+	// CheckOrigin is Origin を検証する
+	// 	nil の場合は Origin ヘッダーが無いか、Host と一致する場合のみ許可する
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols is サーバーが対応するサブプロトコル (優先順)
+	Subprotocols []string
+	// EnableCompression is permessage-deflate (RFC 7692) を有効にする
+	EnableCompression bool
+	// ReadLimit is 受信するメッセージの上限バイト数 (0 は DefaultReadLimit、負の値は無制限)
+	// 	超えた場合は 1009 で切断する
+	ReadLimit int64
+	// HandshakeTimeout is 101 レスポンスの書き込みのタイムアウト
+	HandshakeTimeout time.Duration
+	// ReadBufferSize, WriteBufferSize is I/O バッファのサイズ (0 は 4096)
+	ReadBufferSize  int
+	WriteBufferSize int
+}
+
+// Upgrade is opening handshake を行い、コネクションを返す
+// 	失敗した場合は *HandshakeError を返し、レスポンスは書き込まない
+// 	w.Header() に設定済みのヘッダー (Set-Cookie, X-Request-ID など) は 101 レスポンスに含める
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, &HandshakeError{Status: http.StatusMethodNotAllowed, Message: "request method is not GET"}
+	}
+	if !headerContainsToken(r.Header, constant.HeaderConnection, "upgrade") {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "'upgrade' token not found in 'Connection' header"}
+	}
+	if !headerContainsToken(r.Header, constant.HeaderUpgrade, "websocket") {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "'websocket' token not found in 'Upgrade' header"}
+	}
+	if r.Header.Get(headerSecWebSocketVersion) != "13" {
+		w.Header().Set(headerSecWebSocketVersion, "13")
+		return nil, &HandshakeError{Status: http.StatusUpgradeRequired, Message: "unsupported version"}
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, &HandshakeError{Status: http.StatusForbidden, Message: "request origin not allowed"}
+	}
+	key := r.Header.Get(headerSecWebSocketKey)
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, &HandshakeError{Status: http.StatusBadRequest, Message: "'Sec-WebSocket-Key' header is invalid"}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, &HandshakeError{Status: http.StatusInternalServerError, Message: "response does not implement http.Hijacker"}
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+	compress := u.EnableCompression && negotiateDeflate(r.Header)
+
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, &HandshakeError{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+	if brw.Reader.Buffered() > 0 {
+		// クライアントが handshake の完了前にデータを送ってきた
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data before handshake is complete")
+	}
+
+	buf := &strings.Builder{}
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(buf, "%s: %s\r\n", headerSecWebSocketAccept, computeAcceptKey(key))
+	if subprotocol != "" {
+		fmt.Fprintf(buf, "%s: %s\r\n", headerSecWebSocketProtocol, subprotocol)
+	}
+	if compress {
+		fmt.Fprintf(buf, "%s: %s; server_no_context_takeover; client_no_context_takeover\r\n", headerSecWebSocketExtensions, extensionDeflate)
+	}
+	for k, values := range w.Header() {
+		switch http.CanonicalHeaderKey(k) {
+		case constant.HeaderContentType, constant.HeaderXContentTypeOptions, headerSecWebSocketProtocol, headerSecWebSocketExtensions:
+			continue
+		}
+		for _, v := range values {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, strings.NewReplacer("\r", "", "\n", "").Replace(v))
+		}
+	}
+	buf.WriteString("\r\n")
+
+	if u.HandshakeTimeout > 0 {
+		_ = netConn.SetWriteDeadline(time.Now().Add(u.HandshakeTimeout))
+	}
+	if _, err := netConn.Write([]byte(buf.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if u.HandshakeTimeout > 0 {
+		_ = netConn.SetWriteDeadline(time.Time{})
+	}
+
+	conn := newConn(netConn, bufio.NewReaderSize(netConn, bufferSize(u.ReadBufferSize)), bufferSize(u.WriteBufferSize))
+	conn.subprotocol = subprotocol
+	conn.compress = compress
+	conn.readLimit = readLimit(u.ReadLimit)
+	return conn, nil
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	requested := headerTokens(r.Header, headerSecWebSocketProtocol)
+	for _, server := range u.Subprotocols {
+		for _, client := range requested {
+			if client == server {
+				return client
+			}
+		}
+	}
+	return ""
+}
+
+func readLimit(limit int64) int64 {
+	if limit == 0 {
+		return DefaultReadLimit
+	}
+	return limit
+}
+
+func bufferSize(size int) int {
+	if size <= 0 {
+		return 4096
+	}
+	return size
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sameOrigin is Origin ヘッダーが無い (ブラウザ以外) か、Host と一致する場合に true を返す
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get(constant.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// AllowOrigins is 指定した Origin のみを許可する CheckOrigin を返す
+// 	"*" を含む場合は全て許可する
+func AllowOrigins(origins ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get(constant.HeaderOrigin)
+		if origin == "" {
+			return true
+		}
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func headerTokens(header http.Header, name string) []string {
+	tokens := make([]string, 0)
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, t := range headerTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateDeflate is クライアントが permessage-deflate を要求しているかを返す
+// 	サーバーは常に context takeover 無しで応答するため、パラメータは無視できる
+func negotiateDeflate(header http.Header) bool {
+	for _, ext := range headerTokens(header, headerSecWebSocketExtensions) {
+		name := strings.TrimSpace(strings.SplitN(ext, ";", 2)[0])
+		if strings.EqualFold(name, extensionDeflate) {
+			return true
+		}
+	}
+	return false
+}
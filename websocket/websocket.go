@@ -0,0 +1,39 @@
+package websocket
+
+import (
+	"net/http"
+
+	"github.com/n-creativesystem/go-fwncs"
+)
+
+// HandlerFunc is アップグレード後に呼び出される handler
+// 	handler が戻るとコネクションは閉じられる
+type HandlerFunc func(c fwncs.Context, conn *Conn)
+
+// Handler is fwncs のミドルウェアチェーンの中で WebSocket にアップグレードする
+// 	Auth や RequestID などの前段のミドルウェアはアップグレード前に実行される
+// 	upgrader が nil の場合はデフォルト設定を使う
+func Handler(upgrader *Upgrader, h HandlerFunc) fwncs.HandlerFunc {
+	if upgrader == nil {
+		upgrader = &Upgrader{}
+	}
+	return func(c fwncs.Context) {
+		if !c.IsWebSocket() {
+			c.AbortWithStatusAndMessage(http.StatusBadRequest, fwncs.NewDefaultResponseBody(http.StatusBadRequest, "websocket upgrade required"))
+			return
+		}
+		conn, err := upgrader.Upgrade(c.Writer(), c.Request())
+		if err != nil {
+			c.Error(err)
+			if e, ok := err.(*HandshakeError); ok {
+				c.AbortWithStatusAndMessage(e.Status, fwncs.NewDefaultResponseBody(e.Status, e.Error()))
+			} else {
+				c.Logger().Error(err)
+				c.Skip()
+			}
+			return
+		}
+		defer conn.Close()
+		h(c, conn)
+	}
+}
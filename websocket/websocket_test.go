@@ -0,0 +1,296 @@
+package websocket_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-creativesystem/go-fwncs"
+	"github.com/n-creativesystem/go-fwncs/constant"
+	"github.com/n-creativesystem/go-fwncs/tests"
+	"github.com/n-creativesystem/go-fwncs/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dial(t *testing.T, srv *httptest.Server, header http.Header) *testClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{conn: conn, br: br, resp: resp}
+}
+
+func (c *testClient) writeFrame(opcode byte, rsv1 bool, payload []byte) error {
+	b0 := opcode | 0x80
+	if rsv1 {
+		b0 |= 0x40
+	}
+	buf := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		buf = append(buf, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		buf = append(buf, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(len(payload)))
+		buf = append(append(buf, 0x80|127), ext...)
+	}
+	mask := []byte{1, 2, 3, 4}
+	buf = append(buf, mask...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func (c *testClient) readFrame() (opcode byte, rsv1 bool, payload []byte, err error) {
+	h := make([]byte, 2)
+	if _, err = io.ReadFull(c.br, h); err != nil {
+		return
+	}
+	opcode, rsv1 = h[0]&0x0f, h[0]&0x40 != 0
+	length := int(h[1] & 0x7f)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		_, err = io.ReadFull(c.br, ext)
+		length = int(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		_, err = io.ReadFull(c.br, ext)
+		length = int(binary.BigEndian.Uint64(ext))
+	}
+	if err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	return
+}
+
+func newServer(upgrader *websocket.Upgrader) *httptest.Server {
+	router := fwncs.New()
+	router.Use(fwncs.RequestID())
+	router.GET("/ws", websocket.Handler(upgrader, func(c fwncs.Context, conn *websocket.Conn) {
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, p); err != nil {
+				return
+			}
+		}
+	}))
+	return httptest.NewServer(router)
+}
+
+func TestWebSocket(t *testing.T) {
+	tt := tests.TestFrames{
+		{
+			Name: "echo",
+			Fn: func(t *testing.T) {
+				srv := newServer(&websocket.Upgrader{ReadLimit: -1})
+				defer srv.Close()
+				client := dial(t, srv, nil)
+				defer client.conn.Close()
+				assert.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+				assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", client.resp.Header.Get("Sec-WebSocket-Accept"))
+				assert.NotEmpty(t, client.resp.Header.Get(constant.HeaderXRequestID))
+
+				assert.NoError(t, client.writeFrame(websocket.TextMessage, false, []byte("こんにちは")))
+				opcode, _, payload, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.TextMessage), opcode)
+				assert.Equal(t, "こんにちは", string(payload))
+
+				large := bytes.Repeat([]byte("a"), 70000)
+				assert.NoError(t, client.writeFrame(websocket.BinaryMessage, false, large))
+				opcode, _, payload, err = client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.BinaryMessage), opcode)
+				assert.Equal(t, large, payload)
+
+				assert.NoError(t, client.writeFrame(websocket.PingMessage, false, []byte("ping")))
+				opcode, _, payload, err = client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.PongMessage), opcode)
+				assert.Equal(t, "ping", string(payload))
+
+				assert.NoError(t, client.writeFrame(websocket.CloseMessage, false, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")))
+				opcode, _, payload, err = client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.CloseMessage), opcode)
+				assert.Equal(t, uint16(websocket.CloseNormalClosure), binary.BigEndian.Uint16(payload))
+			},
+		},
+		{
+			Name: "fragmented message",
+			Fn: func(t *testing.T) {
+				srv := newServer(nil)
+				defer srv.Close()
+				client := dial(t, srv, nil)
+				defer client.conn.Close()
+				// fin=0 text, ping, fin=1 continuation
+				_, _ = client.conn.Write([]byte{0x01, 0x83, 0, 0, 0, 0, 'a', 'b', 'c'})
+				assert.NoError(t, client.writeFrame(websocket.PingMessage, false, nil))
+				_, _ = client.conn.Write([]byte{0x80, 0x83, 0, 0, 0, 0, 'd', 'e', 'f'})
+				opcode, _, _, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.PongMessage), opcode)
+				opcode, _, payload, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.TextMessage), opcode)
+				assert.Equal(t, "abcdef", string(payload))
+			},
+		},
+		{
+			Name: "permessage-deflate",
+			Fn: func(t *testing.T) {
+				srv := newServer(&websocket.Upgrader{EnableCompression: true})
+				defer srv.Close()
+				client := dial(t, srv, http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}})
+				defer client.conn.Close()
+				assert.Contains(t, client.resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+				buf := &bytes.Buffer{}
+				w, _ := flate.NewWriter(buf, flate.BestCompression)
+				_, _ = w.Write([]byte(strings.Repeat("compressed ", 10)))
+				_ = w.Flush()
+				compressed := bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+				assert.NoError(t, client.writeFrame(websocket.TextMessage, true, compressed))
+
+				opcode, rsv1, payload, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.TextMessage), opcode)
+				assert.True(t, rsv1)
+				r := flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff})))
+				plain, err := ioutil.ReadAll(r)
+				assert.NoError(t, err)
+				assert.Equal(t, strings.Repeat("compressed ", 10), string(plain))
+			},
+		},
+		{
+			Name: "read limit",
+			Fn: func(t *testing.T) {
+				srv := newServer(&websocket.Upgrader{ReadLimit: 8})
+				defer srv.Close()
+				client := dial(t, srv, nil)
+				defer client.conn.Close()
+				assert.NoError(t, client.writeFrame(websocket.TextMessage, false, []byte("too large message")))
+				opcode, _, payload, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.CloseMessage), opcode)
+				assert.Equal(t, uint16(websocket.CloseMessageTooBig), binary.BigEndian.Uint16(payload))
+			},
+		},
+		{
+			Name: "default read limit",
+			Fn: func(t *testing.T) {
+				srv := newServer(nil)
+				defer srv.Close()
+				client := dial(t, srv, nil)
+				defer client.conn.Close()
+				limit := bytes.Repeat([]byte("a"), websocket.DefaultReadLimit)
+				assert.NoError(t, client.writeFrame(websocket.BinaryMessage, false, limit))
+				opcode, _, payload, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.BinaryMessage), opcode)
+				assert.Equal(t, limit, payload)
+
+				assert.NoError(t, client.writeFrame(websocket.BinaryMessage, false, append(limit, 'a')))
+				opcode, _, payload, err = client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.CloseMessage), opcode)
+				assert.Equal(t, uint16(websocket.CloseMessageTooBig), binary.BigEndian.Uint16(payload))
+			},
+		},
+		{
+			Name: "close codes",
+			Fn: func(t *testing.T) {
+				srv := newServer(nil)
+				defer srv.Close()
+				for code, expected := range map[int]int{
+					websocket.CloseServiceRestart: websocket.CloseServiceRestart,
+					websocket.CloseTryAgainLater:  websocket.CloseTryAgainLater,
+					websocket.CloseBadGateway:     websocket.CloseBadGateway,
+					1015:                          websocket.CloseProtocolError,
+					2000:                          websocket.CloseProtocolError,
+				} {
+					client := dial(t, srv, nil)
+					assert.NoError(t, client.writeFrame(websocket.CloseMessage, false, websocket.FormatCloseMessage(code, "")))
+					opcode, _, payload, err := client.readFrame()
+					assert.NoError(t, err)
+					assert.Equal(t, byte(websocket.CloseMessage), opcode)
+					assert.Equal(t, uint16(expected), binary.BigEndian.Uint16(payload), code)
+					client.conn.Close()
+				}
+			},
+		},
+		{
+			Name: "unmasked frame is protocol error",
+			Fn: func(t *testing.T) {
+				srv := newServer(nil)
+				defer srv.Close()
+				client := dial(t, srv, nil)
+				defer client.conn.Close()
+				_, _ = client.conn.Write([]byte{0x81, 0x01, 'a'})
+				opcode, _, payload, err := client.readFrame()
+				assert.NoError(t, err)
+				assert.Equal(t, byte(websocket.CloseMessage), opcode)
+				assert.Equal(t, uint16(websocket.CloseProtocolError), binary.BigEndian.Uint16(payload))
+			},
+		},
+		{
+			Name: "origin check",
+			Fn: func(t *testing.T) {
+				srv := newServer(nil)
+				defer srv.Close()
+				client := dial(t, srv, http.Header{"Origin": {"https://evil.example.com"}})
+				defer client.conn.Close()
+				assert.Equal(t, http.StatusForbidden, client.resp.StatusCode)
+
+				srv2 := newServer(&websocket.Upgrader{CheckOrigin: websocket.AllowOrigins("https://app.example.com")})
+				defer srv2.Close()
+				client2 := dial(t, srv2, http.Header{"Origin": {"https://app.example.com"}})
+				defer client2.conn.Close()
+				assert.Equal(t, http.StatusSwitchingProtocols, client2.resp.StatusCode)
+			},
+		},
+	}
+	tt.Run(t)
+}